@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/flowshot-io/commander/pkg/commander/services/frontend"
+)
+
+const defaultFile = "api/openapi.json"
+
+func main() {
+	file := defaultFile
+	if len(os.Args) > 1 {
+		file = os.Args[1]
+	}
+
+	doc, err := frontend.OpenAPI()
+	if err != nil {
+		fmt.Println("Unable to generate OpenAPI document:", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(file, append(doc, '\n'), 0644); err != nil {
+		fmt.Println("Unable to write OpenAPI document:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/flowshot-io/commander/pkg/commander/replay"
+	"github.com/flowshot-io/x/pkg/logger"
+	"github.com/flowshot-io/x/pkg/temporallogger"
+)
+
+const defaultDir = "testdata/histories"
+
+func main() {
+	dir := defaultDir
+	if len(os.Args) > 1 {
+		dir = os.Args[1]
+	}
+
+	files, err := replay.Histories(dir)
+	if err != nil {
+		fmt.Println("Unable to load histories:", err)
+		os.Exit(1)
+	}
+
+	logger := temporallogger.New(logger.NoOp())
+
+	failed := 0
+	for _, file := range files {
+		if err := replay.Replay(logger, file); err != nil {
+			fmt.Println("FAIL", err)
+			failed++
+			continue
+		}
+
+		fmt.Println("ok  ", file)
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d histories failed to replay\n", failed, len(files))
+		os.Exit(1)
+	}
+}
@@ -6,9 +6,15 @@ require (
 	github.com/flowshot-io/commander-client-go v0.0.0-20230429224247-a3aa99d64cf0
 	github.com/flowshot-io/polystore v0.0.0-20230519144818-0fc19a23ee91
 	github.com/flowshot-io/x v0.0.0-20230525145942-2ef13ec50687
+	github.com/go-playground/validator/v10 v10.13.0
+	github.com/golang-jwt/jwt/v4 v4.5.2
+	github.com/stretchr/testify v1.8.2
+	go.temporal.io/api v1.16.0
 	go.temporal.io/sdk v1.21.1
 	golang.org/x/exp v0.0.0-20220929160808-de9c53c655b9
+	golang.org/x/sys v0.7.0
 	google.golang.org/grpc v1.54.0
+	google.golang.org/protobuf v1.30.0
 )
 
 require (
@@ -19,7 +25,6 @@ require (
 	github.com/facebookgo/clock v0.0.0-20150410010913-600d898af40a // indirect
 	github.com/go-playground/locales v0.14.1 // indirect
 	github.com/go-playground/universal-translator v0.18.1 // indirect
-	github.com/go-playground/validator/v10 v10.13.0 // indirect
 	github.com/gogo/googleapis v1.4.1 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/gogo/status v1.1.1 // indirect
@@ -37,17 +42,13 @@ require (
 	github.com/rs/zerolog v1.29.1 // indirect
 	github.com/spf13/afero v1.9.5 // indirect
 	github.com/stretchr/objx v0.5.0 // indirect
-	github.com/stretchr/testify v1.8.2 // indirect
-	go.temporal.io/api v1.16.0 // indirect
 	go.uber.org/atomic v1.10.0 // indirect
 	golang.org/x/crypto v0.7.0 // indirect
 	golang.org/x/net v0.9.0 // indirect
 	golang.org/x/sync v0.1.0 // indirect
-	golang.org/x/sys v0.7.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
 	golang.org/x/time v0.1.0 // indirect
 	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
-	google.golang.org/protobuf v1.30.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	logur.dev/adapter/zerolog v0.6.0 // indirect
@@ -0,0 +1,511 @@
+// Package artifactcache keeps downloaded artifacts on a node so repeated pulls
+// of the same artifact are served from disk.
+//
+// Archives are stored content addressed under blobs/<sha256>.tar.gz, hashed as
+// they are downloaded from the store, and an index maps artifact names to their
+// digest and to when the store last had them written. Every pull asks the store
+// for the latter, so an artifact re-uploaded under an existing name is
+// downloaded again rather than served from the cache.
+package artifactcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flowshot-io/polystore/pkg/types"
+	"github.com/flowshot-io/x/pkg/artifact"
+	"github.com/flowshot-io/x/pkg/logger"
+)
+
+const (
+	// DefaultDir is used when Options does not set Dir.
+	DefaultDir = "cache"
+	// DefaultMaxSize is used when Options does not set MaxSize.
+	DefaultMaxSize int64 = 50 << 30
+
+	blobsDir  = "blobs"
+	indexFile = "index.json"
+	blobExt   = ".tar.gz"
+)
+
+// ErrCorrupt is returned when a cached archive no longer matches its digest.
+var ErrCorrupt = errors.New("cached artifact is corrupt")
+
+type (
+	Options struct {
+		// Store is the storage the artifact service keeps artifacts in.
+		Store  types.Storage
+		Logger logger.Logger
+		// Dir is the directory the cache is kept in.
+		Dir string
+		// MaxSize is the total size in bytes of cached archives kept before the
+		// least recently used ones are evicted.
+		MaxSize int64
+	}
+
+	// Cache is a size bounded, content addressed cache of artifacts. It is safe
+	// for concurrent use.
+	Cache struct {
+		store   types.Storage
+		logger  logger.Logger
+		dir     string
+		maxSize int64
+
+		mu sync.Mutex
+		// index maps artifact names to the archive cached for them.
+		index map[string]entry
+		blobs map[string]*blob
+		size  int64
+		// pulls holds the in flight downloads by artifact name.
+		pulls map[string]*pull
+	}
+
+	entry struct {
+		Digest string
+		// Modified is when the store last had the artifact written, as it reported when the archive was downloaded.
+		Modified time.Time
+	}
+
+	blob struct {
+		size     int64
+		lastUsed time.Time
+		// refs counts the extractions reading the blob, which is never evicted while in use.
+		refs int
+	}
+
+	pull struct {
+		done chan struct{}
+	}
+)
+
+// New opens the cache in opts.Dir, picking up archives cached by a previous process.
+func New(opts Options) (*Cache, error) {
+	if opts.Logger == nil {
+		opts.Logger = logger.NoOp()
+	}
+
+	if opts.Store == nil {
+		return nil, fmt.Errorf("store is required")
+	}
+
+	if opts.Dir == "" {
+		opts.Dir = DefaultDir
+	}
+
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+
+	c := &Cache{
+		store:   opts.Store,
+		logger:  opts.Logger,
+		dir:     opts.Dir,
+		maxSize: opts.MaxSize,
+		index:   map[string]entry{},
+		blobs:   map[string]*blob{},
+		pulls:   map[string]*pull{},
+	}
+
+	if err := c.load(); err != nil {
+		return nil, fmt.Errorf("unable to load artifact cache: %w", err)
+	}
+
+	return c, nil
+}
+
+// Pull extracts the named artifact into destinationPath, downloading it only
+// when it is not cached, has been written to the store since it was cached or
+// its cached archive fails the integrity check. When reserve is not nil it is
+// called with the size of the archive before it is extracted, and an error it
+// returns fails the pull.
+func (c *Cache) Pull(ctx context.Context, artifactName string, destinationPath string, reserve func(size int64) error) error {
+	object, err := c.store.StatWithContext(ctx, storePath(artifactName))
+	if err != nil {
+		return err
+	}
+
+	for {
+		digest, ok := c.acquire(artifactName, object.LastModified)
+		if ok {
+			err := c.extract(artifactName, digest, destinationPath, reserve)
+			c.release(digest)
+			if !errors.Is(err, ErrCorrupt) {
+				return err
+			}
+
+			c.logger.Warn("Evicting corrupt cached artifact", map[string]interface{}{"Artifact": artifactName, "Digest": digest})
+			c.evict(digest)
+		}
+
+		// Only one download per artifact runs at a time. Other callers wait for it and
+		// then read the cache, or download it themselves if it failed.
+		p, leader := c.startPull(artifactName)
+		if !leader {
+			select {
+			case <-p.done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			continue
+		}
+
+		err := c.download(ctx, artifactName, object.LastModified, destinationPath, reserve)
+		c.finishPull(artifactName, p)
+		return err
+	}
+}
+
+// Artifacts returns the names of the cached artifacts.
+func (c *Cache) Artifacts() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.index))
+	for name := range c.index {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Size returns the size of the cached archive of the named artifact.
+func (c *Cache) Size(artifactName string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.blobs[c.index[artifactName].Digest]
+	if !ok {
+		return 0, false
+	}
+
+	return b.size, true
+}
+
+// acquire returns the digest cached for artifactName if it was cached since the store last had it
+// written at modified, and pins its blob until release.
+func (c *Cache) acquire(artifactName string, modified time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.index[artifactName]
+	if !ok {
+		return "", false
+	}
+
+	b, ok := c.blobs[e.Digest]
+	if !ok {
+		delete(c.index, artifactName)
+		return "", false
+	}
+
+	// The stale archive is left to be evicted, other artifacts may share it.
+	if !e.Modified.Equal(modified) {
+		c.logger.Info("Cached artifact is stale", map[string]interface{}{"Artifact": artifactName, "Cached": e.Modified, "Modified": modified})
+		return "", false
+	}
+
+	b.refs++
+	b.lastUsed = time.Now()
+	return e.Digest, true
+}
+
+func (c *Cache) release(digest string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if b, ok := c.blobs[digest]; ok {
+		b.refs--
+	}
+
+	// Touch the archive so the access order survives a restart.
+	now := time.Now()
+	_ = os.Chtimes(c.blobPath(digest), now, now)
+}
+
+func (c *Cache) startPull(artifactName string) (*pull, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if p, ok := c.pulls[artifactName]; ok {
+		return p, false
+	}
+
+	p := &pull{done: make(chan struct{})}
+	c.pulls[artifactName] = p
+	return p, true
+}
+
+func (c *Cache) finishPull(artifactName string, p *pull) {
+	c.mu.Lock()
+	delete(c.pulls, artifactName)
+	c.mu.Unlock()
+
+	close(p.done)
+}
+
+// extract loads the cached archive, checking it against its digest, and extracts it into destinationPath.
+func (c *Cache) extract(artifactName string, digest string, destinationPath string, reserve func(size int64) error) error {
+	f, err := os.Open(c.blobPath(digest))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrCorrupt
+		}
+
+		return err
+	}
+	defer f.Close()
+
+	if reserve != nil {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		if err := reserve(info.Size()); err != nil {
+			return err
+		}
+	}
+
+	hash := sha256.New()
+	a := artifact.New(artifactName)
+	if err := a.LoadFromReader(io.TeeReader(f, hash)); err != nil {
+		return fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+
+	// Drain anything after the end of the archive so the whole file is hashed.
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+
+	if hex.EncodeToString(hash.Sum(nil)) != digest {
+		return ErrCorrupt
+	}
+
+	return a.ExtractToDirectory(destinationPath)
+}
+
+// download streams the artifact from the store into a temporary file, hashing it on the way, extracts
+// it into destinationPath and adds the file to the cache as the artifact written at modified.
+func (c *Cache) download(ctx context.Context, artifactName string, modified time.Time, destinationPath string, reserve func(size int64) error) error {
+	tmp, err := os.CreateTemp(filepath.Join(c.dir, blobsDir), "pull-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := c.store.ReadWithContext(ctx, storePath(artifactName), io.MultiWriter(tmp, hash))
+	if err != nil {
+		return err
+	}
+
+	// The size of an artifact is only known once it is downloaded, so this is the first chance to
+	// check it fits on disk.
+	if reserve != nil {
+		if err := reserve(size); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	a := artifact.New(artifactName)
+	if err := a.LoadFromReader(tmp); err != nil {
+		return err
+	}
+
+	if err := a.ExtractToDirectory(destinationPath); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// Failing to cache the artifact does not fail the pull.
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if err := c.add(artifactName, entry{Digest: digest, Modified: modified}, tmp.Name(), size); err != nil {
+		c.logger.Warn("Unable to cache artifact", map[string]interface{}{"Artifact": artifactName, "Error": err.Error()})
+	}
+
+	return nil
+}
+
+// add moves the downloaded archive at path to its content address and indexes it.
+func (c *Cache) add(artifactName string, e entry, path string, size int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.blobs[e.Digest]; !ok {
+		if err := os.Rename(path, c.blobPath(e.Digest)); err != nil {
+			return err
+		}
+
+		c.blobs[e.Digest] = &blob{size: size}
+		c.size += size
+	}
+
+	c.blobs[e.Digest].lastUsed = time.Now()
+	c.index[artifactName] = e
+
+	c.evictLocked()
+	return c.saveIndexLocked()
+}
+
+func (c *Cache) evict(digest string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeLocked(digest)
+	if err := c.saveIndexLocked(); err != nil {
+		c.logger.Warn("Unable to save artifact cache index", map[string]interface{}{"Error": err.Error()})
+	}
+}
+
+// evictLocked removes the least recently used blobs that are not in use until the cache fits in maxSize.
+func (c *Cache) evictLocked() {
+	if c.size <= c.maxSize {
+		return
+	}
+
+	digests := make([]string, 0, len(c.blobs))
+	for digest := range c.blobs {
+		digests = append(digests, digest)
+	}
+
+	sort.Slice(digests, func(i, j int) bool {
+		return c.blobs[digests[i]].lastUsed.Before(c.blobs[digests[j]].lastUsed)
+	})
+
+	for _, digest := range digests {
+		if c.size <= c.maxSize {
+			return
+		}
+
+		if c.blobs[digest].refs > 0 {
+			continue
+		}
+
+		c.logger.Info("Evicting cached artifact", map[string]interface{}{"Digest": digest, "Size": c.blobs[digest].size})
+		c.removeLocked(digest)
+	}
+}
+
+func (c *Cache) removeLocked(digest string) {
+	if b, ok := c.blobs[digest]; ok {
+		c.size -= b.size
+		delete(c.blobs, digest)
+	}
+
+	for name, e := range c.index {
+		if e.Digest == digest {
+			delete(c.index, name)
+		}
+	}
+
+	if err := os.Remove(c.blobPath(digest)); err != nil && !os.IsNotExist(err) {
+		c.logger.Warn("Unable to remove cached artifact", map[string]interface{}{"Digest": digest, "Error": err.Error()})
+	}
+}
+
+// load reads the index and the archives left by a previous process, dropping
+// index entries whose archive is missing and archives no entry refers to.
+func (c *Cache) load() error {
+	if err := os.MkdirAll(filepath.Join(c.dir, blobsDir), 0o755); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.dir, indexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// An index written before entries recorded when the artifact was modified is unreadable, and
+	// the archives it refers to are removed below.
+	index := map[string]entry{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &index); err != nil {
+			c.logger.Warn("Ignoring unreadable artifact cache index", map[string]interface{}{"Error": err.Error()})
+			index = map[string]entry{}
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(c.dir, blobsDir))
+	if err != nil {
+		return err
+	}
+
+	referenced := map[string]bool{}
+	for _, e := range index {
+		referenced[e.Digest] = true
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(c.dir, blobsDir, entry.Name())
+		digest := strings.TrimSuffix(entry.Name(), blobExt)
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), blobExt) || !referenced[digest] {
+			_ = os.RemoveAll(path)
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		c.blobs[digest] = &blob{size: info.Size(), lastUsed: info.ModTime()}
+		c.size += info.Size()
+	}
+
+	for name, e := range index {
+		if _, ok := c.blobs[e.Digest]; ok {
+			c.index[name] = e
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evictLocked()
+	return c.saveIndexLocked()
+}
+
+// saveIndexLocked atomically replaces the index file.
+func (c *Cache) saveIndexLocked() error {
+	data, err := json.Marshal(c.index)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(c.dir, indexFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(c.dir, indexFile))
+}
+
+// storePath returns the path the artifact service keeps the archive of the named artifact at.
+func storePath(artifactName string) string {
+	return artifact.New(artifactName).GetName()
+}
+
+func (c *Cache) blobPath(digest string) string {
+	return filepath.Join(c.dir, blobsDir, digest+blobExt)
+}
@@ -0,0 +1,166 @@
+package artifactcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flowshot-io/polystore/pkg/services/fs"
+	"github.com/flowshot-io/polystore/pkg/types"
+	"github.com/flowshot-io/x/pkg/artifact"
+	"github.com/flowshot-io/x/pkg/artifactservice"
+)
+
+// newStore returns a store in a temporary directory and an artifact client keeping artifacts in it.
+func newStore(t *testing.T) (types.Storage, artifactservice.ArtifactServiceClient) {
+	t.Helper()
+
+	store, err := fs.New(fs.Options{WorkingDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := artifactservice.New(artifactservice.Options{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, client
+}
+
+// upload uploads an artifact holding a single file with content.
+func upload(t *testing.T, client artifactservice.ArtifactServiceClient, name string, content string) {
+	t.Helper()
+
+	a := artifact.New(name)
+	if err := a.AddFile("", "scene.txt", []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.UploadArtifact(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pullContent pulls the artifact through the cache and returns the content of its file.
+func pullContent(t *testing.T, c *Cache, name string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := c.Pull(context.Background(), name, dir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "scene.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestPullRevalidatesReuploadedArtifact(t *testing.T) {
+	store, client := newStore(t)
+	c, err := New(Options{Store: store, Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload(t, client, "project", "v1")
+	if got := pullContent(t, c, "project"); got != "v1" {
+		t.Fatalf("pull = %q, want v1", got)
+	}
+
+	// Make sure the store reports a different modification time for the new upload.
+	time.Sleep(10 * time.Millisecond)
+	upload(t, client, "project", "v2")
+
+	if got := pullContent(t, c, "project"); got != "v2" {
+		t.Fatalf("pull after re-upload = %q, want v2", got)
+	}
+
+	// An artifact the store no longer has is not served from the cache.
+	if err := store.DeleteWithContext(context.Background(), storePath("project")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Pull(context.Background(), "project", t.TempDir(), nil); err == nil {
+		t.Fatal("pull of a deleted artifact succeeded")
+	}
+}
+
+func TestPullDigestsDownloadedArchive(t *testing.T) {
+	store, client := newStore(t)
+	dir := t.TempDir()
+	c, err := New(Options{Store: store, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload(t, client, "project", "v1")
+	pullContent(t, c, "project")
+
+	archive, err := os.ReadFile(filepath.Join(store.(*fs.Backend).Root, storePath("project")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(archive)
+	digest := hex.EncodeToString(sum[:])
+	if _, err := os.Stat(c.blobPath(digest)); err != nil {
+		t.Fatalf("archive not cached under the digest of the stored archive: %v", err)
+	}
+
+	// A cache reopened from disk serves the archive without downloading it again.
+	reopened, err := New(Options{Store: store, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := reopened.Size("project"); !ok {
+		t.Fatal("reopened cache lost the artifact")
+	}
+
+	if got := pullContent(t, reopened, "project"); got != "v1" {
+		t.Fatalf("pull from reopened cache = %q, want v1", got)
+	}
+}
+
+func TestPullReservesBeforeExtracting(t *testing.T) {
+	store, client := newStore(t)
+	c, err := New(Options{Store: store, Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload(t, client, "project", "v1")
+
+	full := errors.New("disk full")
+	for _, pull := range []string{"download", "cached"} {
+		dir := t.TempDir()
+		var reserved int64
+		err := c.Pull(context.Background(), "project", dir, func(size int64) error {
+			reserved = size
+			return full
+		})
+		if !errors.Is(err, full) {
+			t.Fatalf("%s pull = %v, want the reserve error", pull, err)
+		}
+
+		if reserved <= 0 {
+			t.Fatalf("%s pull reserved %d bytes", pull, reserved)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "scene.txt")); !os.IsNotExist(err) {
+			t.Fatalf("%s pull extracted the artifact after the reserve failed", pull)
+		}
+
+		// Cache the artifact for the next pull.
+		pullContent(t, c, "project")
+	}
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+type (
+	// APIKey is a static key and the principal it authenticates as.
+	APIKey struct {
+		Name string
+		Key  string
+	}
+
+	apiKeys struct {
+		// keys maps the SHA-256 of each key to its name, so lookups compare fixed size digests.
+		keys map[[sha256.Size]byte]string
+	}
+)
+
+// NewAPIKeys returns an Authenticator accepting the given keys, sent as MetadataAPIKey.
+func NewAPIKeys(keys []APIKey) (Authenticator, error) {
+	a := &apiKeys{keys: map[[sha256.Size]byte]string{}}
+	for _, k := range keys {
+		if k.Name == "" || k.Key == "" {
+			return nil, fmt.Errorf("api key name and key are required")
+		}
+
+		a.keys[sha256.Sum256([]byte(k.Key))] = k.Name
+	}
+
+	return a, nil
+}
+
+func (a *apiKeys) Authenticate(ctx context.Context, md metadata.MD) (Principal, error) {
+	values := md.Get(MetadataAPIKey)
+	if len(values) == 0 {
+		return Principal{}, ErrNoCredentials
+	}
+
+	sum := sha256.Sum256([]byte(strings.TrimSpace(values[0])))
+	for digest, name := range a.keys {
+		if subtle.ConstantTimeCompare(sum[:], digest[:]) == 1 {
+			return Principal{Subject: name, Method: "api-key"}, nil
+		}
+	}
+
+	return Principal{}, ErrInvalidCredentials
+}
@@ -0,0 +1,138 @@
+// Package auth authenticates the callers of the frontend gRPC server.
+//
+// An Authenticator reads the credentials of a call from its metadata. The
+// interceptors reject calls no authenticator accepts and attach the
+// authenticated Principal to the context of the calls they let through.
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// MetadataAuthorization carries a bearer token as "Bearer <token>".
+	MetadataAuthorization = "authorization"
+	// MetadataAPIKey carries a static API key.
+	MetadataAPIKey = "x-api-key"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the call carries no credentials of its kind.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned by an Authenticator when the credentials of the call are rejected.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type (
+	// Principal is the authenticated caller of a request.
+	Principal struct {
+		Subject string
+		// Method is how the principal authenticated, such as "api-key" or "jwt".
+		Method string
+	}
+
+	// Authenticator authenticates a call from its incoming metadata.
+	Authenticator interface {
+		Authenticate(ctx context.Context, md metadata.MD) (Principal, error)
+	}
+
+	Options struct {
+		Authenticator Authenticator
+		// PublicMethods are the full method names, such as "/grpc.health.v1.Health/Check",
+		// served without authentication.
+		PublicMethods []string
+	}
+
+	chain []Authenticator
+
+	principalKey struct{}
+
+	// serverStream overrides the context of a stream with the authenticated one.
+	serverStream struct {
+		grpc.ServerStream
+		ctx context.Context
+	}
+)
+
+// Chain returns an Authenticator that tries each authenticator in turn, using the
+// first one that finds credentials of its kind on the call.
+func Chain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+func (c chain) Authenticate(ctx context.Context, md metadata.MD) (Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(ctx, md)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+
+		return p, err
+	}
+
+	return Principal{}, ErrNoCredentials
+}
+
+// NewContext returns a copy of ctx carrying p.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal authenticated for the request of ctx.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// UnaryServerInterceptor authenticates unary calls.
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, opts, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authenticates streaming calls.
+func StreamServerInterceptor(opts Options) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), opts, info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(ctx context.Context, opts Options, fullMethod string) (context.Context, error) {
+	for _, m := range opts.PublicMethods {
+		if m == fullMethod {
+			return ctx, nil
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	p, err := opts.Authenticator.Authenticate(ctx, md)
+	if errors.Is(err, ErrNoCredentials) {
+		return nil, status.Error(codes.Unauthenticated, "credentials are required")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "authentication failed: %v", err)
+	}
+
+	return NewContext(ctx, p), nil
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func newAuthenticator(t *testing.T) Authenticator {
+	t.Helper()
+
+	apiKeys, err := NewAPIKeys([]APIKey{{Name: "ci", Key: "ci-key"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwtAuth, err := NewJWT(JWTOptions{HMACSecret: secret, Issuer: "issuer", Audience: "commander"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Chain(apiKeys, jwtAuth)
+}
+
+func TestAPIKeys(t *testing.T) {
+	a, err := NewAPIKeys([]APIKey{{Name: "ci", Key: "ci-key"}, {Name: "ops", Key: "ops-key"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		md      metadata.MD
+		want    Principal
+		wantErr error
+	}{
+		{metadata.Pairs(MetadataAPIKey, "ci-key"), Principal{Subject: "ci", Method: "api-key"}, nil},
+		{metadata.Pairs(MetadataAPIKey, " ops-key "), Principal{Subject: "ops", Method: "api-key"}, nil},
+		{metadata.Pairs(MetadataAPIKey, "other-key"), Principal{}, ErrInvalidCredentials},
+		{metadata.Pairs(MetadataAPIKey, ""), Principal{}, ErrInvalidCredentials},
+		{metadata.MD{}, Principal{}, ErrNoCredentials},
+	}
+
+	for _, tt := range tests {
+		p, err := a.Authenticate(context.Background(), tt.md)
+		if !errors.Is(err, tt.wantErr) || p != tt.want {
+			t.Errorf("Authenticate(%v) = %+v, %v, want %+v, %v", tt.md, p, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNewAPIKeysInvalid(t *testing.T) {
+	for _, keys := range [][]APIKey{{{Name: "ci"}}, {{Key: "ci-key"}}} {
+		if _, err := NewAPIKeys(keys); err == nil {
+			t.Errorf("NewAPIKeys(%+v) = nil error, want an error", keys)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor(Options{
+		Authenticator: newAuthenticator(t),
+		PublicMethods: []string{"/grpc.health.v1.Health/Check"},
+	})
+
+	tests := []struct {
+		name    string
+		method  string
+		md      metadata.MD
+		want    Principal
+		wantErr codes.Code
+	}{
+		{"api key", "/commander.Service/Create", metadata.Pairs(MetadataAPIKey, "ci-key"), Principal{Subject: "ci", Method: "api-key"}, codes.OK},
+		{"jwt", "/commander.Service/Create", bearer(sign(t, jwt.SigningMethodHS256, "", secret, claims(nil))), Principal{Subject: "alice", Method: "jwt"}, codes.OK},
+		{"no credentials", "/commander.Service/Create", nil, Principal{}, codes.Unauthenticated},
+		{"empty metadata", "/commander.Service/Create", metadata.MD{}, Principal{}, codes.Unauthenticated},
+		{"invalid api key", "/commander.Service/Create", metadata.Pairs(MetadataAPIKey, "other-key"), Principal{}, codes.Unauthenticated},
+		{"public method", "/grpc.health.v1.Health/Check", nil, Principal{}, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+
+			called := false
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				p, _ := FromContext(ctx)
+				if p != tt.want {
+					t.Errorf("FromContext() = %+v, want %+v", p, tt.want)
+				}
+
+				return nil, nil
+			})
+
+			if got := status.Code(err); got != tt.wantErr {
+				t.Errorf("interceptor() code = %v, want %v", got, tt.wantErr)
+			}
+
+			if called != (tt.wantErr == codes.OK) {
+				t.Errorf("handler called = %t, want %t", called, tt.wantErr == codes.OK)
+			}
+		})
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	interceptor := StreamServerInterceptor(Options{Authenticator: newAuthenticator(t)})
+	info := &grpc.StreamServerInfo{FullMethod: "/commander.Service/StreamLogs"}
+
+	called := false
+	err := interceptor(nil, &testStream{ctx: context.Background()}, info, func(srv interface{}, stream grpc.ServerStream) error {
+		called = true
+		return nil
+	})
+	if status.Code(err) != codes.Unauthenticated || called {
+		t.Errorf("interceptor() without credentials = %v, handler called %t, want %v", err, called, codes.Unauthenticated)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataAPIKey, "ci-key"))
+	err = interceptor(nil, &testStream{ctx: ctx}, info, func(srv interface{}, stream grpc.ServerStream) error {
+		if p, _ := FromContext(stream.Context()); p.Subject != "ci" {
+			t.Errorf("FromContext() = %+v, want subject ci", p)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Errorf("interceptor() error = %v", err)
+	}
+}
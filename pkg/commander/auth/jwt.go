@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc/metadata"
+)
+
+type (
+	JWTOptions struct {
+		// HMACSecret verifies HS256, HS384 and HS512 tokens.
+		HMACSecret []byte
+		// JWKSFile is a JSON Web Key Set verifying RS, PS and ES tokens. Tokens are
+		// matched to a key by their kid header, or to the only key of their type.
+		JWKSFile string
+		// Issuer and Audience are required of every token when set.
+		Issuer   string
+		Audience string
+	}
+
+	jwtAuthenticator struct {
+		secret   []byte
+		keys     map[string]interface{}
+		issuer   string
+		audience string
+		parser   *jwt.Parser
+	}
+
+	jsonWebKey struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		// RSA keys.
+		N string `json:"n"`
+		E string `json:"e"`
+		// EC keys.
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+)
+
+// NewJWT returns an Authenticator accepting JWTs sent as "Bearer <token>" in MetadataAuthorization.
+// The subject of the token is the principal.
+func NewJWT(opts JWTOptions) (Authenticator, error) {
+	if len(opts.HMACSecret) == 0 && opts.JWKSFile == "" {
+		return nil, fmt.Errorf("hmac secret or jwks file is required")
+	}
+
+	a := &jwtAuthenticator{
+		secret:   opts.HMACSecret,
+		keys:     map[string]interface{}{},
+		issuer:   opts.Issuer,
+		audience: opts.Audience,
+	}
+
+	var methods []string
+	if len(opts.HMACSecret) > 0 {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+
+	if opts.JWKSFile != "" {
+		keys, err := loadJWKS(opts.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+
+		a.keys = keys
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512")
+	}
+
+	a.parser = jwt.NewParser(jwt.WithValidMethods(methods))
+
+	return a, nil
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context, md metadata.MD) (Principal, error) {
+	values := md.Get(MetadataAuthorization)
+	if len(values) == 0 {
+		return Principal{}, ErrNoCredentials
+	}
+
+	scheme, token, ok := strings.Cut(strings.TrimSpace(values[0]), " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return Principal{}, ErrNoCredentials
+	}
+
+	var claims jwt.RegisteredClaims
+	if _, err := a.parser.ParseWithClaims(strings.TrimSpace(token), &claims, a.key); err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	switch {
+	case !claims.VerifyExpiresAt(time.Now(), true):
+		return Principal{}, fmt.Errorf("%w: token has no expiry", ErrInvalidCredentials)
+	case a.issuer != "" && !claims.VerifyIssuer(a.issuer, true):
+		return Principal{}, fmt.Errorf("%w: unexpected issuer", ErrInvalidCredentials)
+	case a.audience != "" && !claims.VerifyAudience(a.audience, true):
+		return Principal{}, fmt.Errorf("%w: unexpected audience", ErrInvalidCredentials)
+	case claims.Subject == "":
+		return Principal{}, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+
+	return Principal{Subject: claims.Subject, Method: "jwt"}, nil
+}
+
+// key returns the key verifying token.
+func (a *jwtAuthenticator) key(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return a.secret, nil
+	}
+
+	if kid, ok := token.Header["kid"].(string); ok {
+		key, ok := a.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+
+		return key, nil
+	}
+
+	var match interface{}
+	for _, key := range a.keys {
+		if keyMatches(token.Method, key) {
+			if match != nil {
+				return nil, errors.New("token has no key id and more than one key matches")
+			}
+
+			match = key
+		}
+	}
+
+	if match == nil {
+		return nil, errors.New("no key matches the token")
+	}
+
+	return match, nil
+}
+
+func keyMatches(method jwt.SigningMethod, key interface{}) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		_, rsaOK := method.(*jwt.SigningMethodRSA)
+		_, pssOK := method.(*jwt.SigningMethodRSAPSS)
+		return rsaOK || pssOK
+	case *ecdsa.PublicKey:
+		_, ok := method.(*jwt.SigningMethodECDSA)
+		return ok
+	default:
+		return false
+	}
+}
+
+// loadJWKS reads the public RSA and EC keys of a JSON Web Key Set, keyed by their kid.
+// Keys without a kid are keyed by their position in the set.
+func loadJWKS(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read jwks file: %w", err)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("unable to parse jwks file: %w", err)
+	}
+
+	keys := map[string]interface{}{}
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks key %d: %w", i, err)
+		}
+
+		id := k.Kid
+		if id == "" {
+			id = fmt.Sprintf("#%d", i)
+		}
+
+		keys[id] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks file %s has no signing keys", path)
+	}
+
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
@@ -2,17 +2,26 @@ package commander
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"time"
 
 	_ "github.com/flowshot-io/polystore/pkg/services/fs"
 	_ "github.com/flowshot-io/polystore/pkg/services/s3"
 
+	"github.com/flowshot-io/commander/pkg/commander/auth"
+	"github.com/flowshot-io/commander/pkg/commander/config"
 	"github.com/flowshot-io/commander/pkg/commander/factory"
+	"github.com/flowshot-io/commander/pkg/commander/listener"
 	"github.com/flowshot-io/commander/pkg/commander/primitives"
+	"github.com/flowshot-io/commander/pkg/commander/renderer"
 	"github.com/flowshot-io/commander/pkg/commander/services/blenderfarm"
 	"github.com/flowshot-io/commander/pkg/commander/services/blendernode"
 	"github.com/flowshot-io/commander/pkg/commander/services/frontend"
+	"github.com/flowshot-io/commander/pkg/commander/tlsreload"
+	"github.com/flowshot-io/polystore/pkg/types"
 	"github.com/flowshot-io/x/pkg/artifactservice"
+	"github.com/flowshot-io/x/pkg/logger"
 	"github.com/flowshot-io/x/pkg/manager"
 	"go.temporal.io/sdk/client"
 )
@@ -58,12 +67,17 @@ func (c *Commander) Start() error {
 		return fmt.Errorf("unable to create temporal client: %w", err)
 	}
 
-	artifactClient, err := c.connectionFactory.ArtifactClient(c.ctx, c.serverOptions.config.Global.Storage.ConnectionString)
+	artifactStore, err := c.connectionFactory.ArtifactStore(c.ctx, c.serverOptions.config.Global.Storage.ConnectionString)
+	if err != nil {
+		return fmt.Errorf("unable to create artifact store: %w", err)
+	}
+
+	artifactClient, err := artifactservice.New(artifactservice.Options{Store: artifactStore})
 	if err != nil {
 		return fmt.Errorf("unable to create artifact client: %w", err)
 	}
 
-	err = c.initServices(temporalClient, artifactClient)
+	err = c.initServices(temporalClient, artifactStore, artifactClient)
 	if err != nil {
 		return fmt.Errorf("unable to init services: %w", err)
 	}
@@ -87,9 +101,40 @@ func ServerOptions(opts []ServerOption) (*serverOptions, error) {
 	return so, nil
 }
 
-func (c *Commander) initServices(temporalClient client.Client, artifactClient artifactservice.ArtifactServiceClient) error {
+func (c *Commander) initServices(temporalClient client.Client, artifactStore types.Storage, artifactClient artifactservice.ArtifactServiceClient) error {
 	if _, ok := c.serverOptions.serviceNames[primitives.FrontendService]; ok {
-		srv, err := frontend.New(frontend.Options{TemporalClient: temporalClient, Logger: c.serverOptions.logger})
+		serviceConfig := c.serverOptions.config.Services[string(primitives.FrontendService)]
+
+		authenticator, err := newAuthenticator(serviceConfig.Auth)
+		if err != nil {
+			return fmt.Errorf("unable to create frontend authenticator: %w", err)
+		}
+
+		tlsConfig, err := newTLSConfig(serviceConfig.TLS, c.serverOptions.logger)
+		if err != nil {
+			return fmt.Errorf("unable to load frontend TLS certificates: %w", err)
+		}
+
+		var gateway *listener.Options
+		if serviceConfig.Gateway.Enabled {
+			gatewayListener := newListenerOptions(serviceConfig.Gateway.Listener)
+			gateway = &gatewayListener
+		}
+
+		srv, err := frontend.New(frontend.Options{
+			TemporalClient:      temporalClient,
+			ArtifactClient:      artifactClient,
+			Logger:              c.serverOptions.logger,
+			Listener:            newListenerOptions(serviceConfig.Listener),
+			Gateway:             gateway,
+			Authenticator:       authenticator,
+			TLS:                 tlsConfig,
+			MaxParallelBatches:  c.serverOptions.config.Global.BlenderFarm.MaxParallelBatches,
+			HistoryThreshold:    c.serverOptions.config.Global.BlenderFarm.HistoryThreshold,
+			CacheRoutingTimeout: time.Duration(c.serverOptions.config.Global.BlenderFarm.CacheRoutingTimeoutSeconds) * time.Second,
+			MaxBatchAttempts:    c.serverOptions.config.Global.BlenderFarm.MaxBatchAttempts,
+			MaxActivityAttempts: c.serverOptions.config.Global.BlenderFarm.MaxActivityAttempts,
+		})
 		if err != nil {
 			return fmt.Errorf("unable to create frontend service: %w", err)
 		}
@@ -101,7 +146,7 @@ func (c *Commander) initServices(temporalClient client.Client, artifactClient ar
 	}
 
 	if _, ok := c.serverOptions.serviceNames[primitives.BlenderFarmService]; ok {
-		srv, err := blenderfarm.New(blenderfarm.Options{TemporalClient: temporalClient, Logger: c.serverOptions.logger})
+		srv, err := blenderfarm.New(blenderfarm.Options{TemporalClient: temporalClient, ArtifactClient: artifactClient, Logger: c.serverOptions.logger})
 		if err != nil {
 			return fmt.Errorf("unable to create blenderfarm service: %w", err)
 		}
@@ -113,7 +158,28 @@ func (c *Commander) initServices(temporalClient client.Client, artifactClient ar
 	}
 
 	if _, ok := c.serverOptions.serviceNames[primitives.BlenderNodeService]; ok {
-		srv, err := blendernode.New(blendernode.Options{TemporalClient: temporalClient, ArtifactClient: artifactClient, Logger: c.serverOptions.logger})
+		cache := c.serverOptions.config.Global.BlenderNode.ArtifactCache
+		ws := c.serverOptions.config.Global.BlenderNode.Workspace
+		rc := c.serverOptions.config.Global.BlenderNode.Renderer
+		srv, err := blendernode.New(blendernode.Options{
+			TemporalClient:         temporalClient,
+			ArtifactClient:         artifactClient,
+			ArtifactStore:          artifactStore,
+			Logger:                 c.serverOptions.logger,
+			CacheDir:               cache.Dir,
+			CacheMaxSize:           cache.MaxSizeMB << 20,
+			WorkspaceRoot:          ws.Root,
+			WorkspaceMinFree:       ws.MinFreeMB << 20,
+			WorkspaceReservation:   ws.ReserveMB << 20,
+			WorkspaceKeepFailedFor: time.Duration(ws.KeepFailedMinutes) * time.Minute,
+			Renderer:               rc.Default,
+			RendererOptions: renderer.Options{
+				RocketblendPath: rc.RocketblendPath,
+				BlenderPath:     rc.BlenderPath,
+				FakeFrameTime:   time.Duration(rc.FakeFrameTimeMs) * time.Millisecond,
+			},
+			AllowBlankFrames: rc.AllowBlankFrames,
+		})
 		if err != nil {
 			return fmt.Errorf("unable to create blendernode service: %w", err)
 		}
@@ -127,3 +193,84 @@ func (c *Commander) initServices(temporalClient client.Client, artifactClient ar
 
 	return nil
 }
+
+// newAuthenticator returns the authenticator configured by conf, or nil when authentication is disabled.
+func newAuthenticator(conf config.Auth) (auth.Authenticator, error) {
+	var authenticators []auth.Authenticator
+
+	if len(conf.APIKeys) > 0 {
+		keys := make([]auth.APIKey, 0, len(conf.APIKeys))
+		for _, k := range conf.APIKeys {
+			keys = append(keys, auth.APIKey{Name: k.Name, Key: k.Key})
+		}
+
+		a, err := auth.NewAPIKeys(keys)
+		if err != nil {
+			return nil, err
+		}
+
+		authenticators = append(authenticators, a)
+	}
+
+	if conf.JWT.HMACSecret != "" || conf.JWT.JWKSFile != "" {
+		a, err := auth.NewJWT(auth.JWTOptions{
+			HMACSecret: []byte(conf.JWT.HMACSecret),
+			JWKSFile:   conf.JWT.JWKSFile,
+			Issuer:     conf.JWT.Issuer,
+			Audience:   conf.JWT.Audience,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		authenticators = append(authenticators, a)
+	}
+
+	if len(authenticators) == 0 {
+		return nil, nil
+	}
+
+	return auth.Chain(authenticators...), nil
+}
+
+// newTLSConfig returns the server TLS config configured by conf, or nil when TLS is disabled.
+func newTLSConfig(conf config.TLS, log logger.Logger) (*tls.Config, error) {
+	if conf.CertFile == "" && conf.KeyFile == "" {
+		if conf.ClientCAFile != "" {
+			return nil, fmt.Errorf("client CA file requires a cert file and key file")
+		}
+
+		return nil, nil
+	}
+
+	reloader, err := tlsreload.New(tlsreload.Options{
+		Logger:        log,
+		CertFile:      conf.CertFile,
+		KeyFile:       conf.KeyFile,
+		ClientCAFile:  conf.ClientCAFile,
+		CheckInterval: time.Duration(conf.ReloadIntervalSeconds) * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reloader.Config(), nil
+}
+
+// newListenerOptions converts the listener config of a service.
+func newListenerOptions(conf config.Listener) listener.Options {
+	return listener.Options{
+		Address:              conf.Address,
+		Port:                 conf.Port,
+		UnixSocket:           conf.UnixSocket,
+		MaxRecvMsgSize:       conf.MaxRecvMsgSizeBytes,
+		MaxSendMsgSize:       conf.MaxSendMsgSizeBytes,
+		MaxConcurrentStreams: conf.MaxConcurrentStreams,
+		Keepalive: listener.Keepalive{
+			MinTime:             time.Duration(conf.Keepalive.MinTimeSeconds) * time.Second,
+			PermitWithoutStream: conf.Keepalive.PermitWithoutStream,
+			Time:                time.Duration(conf.Keepalive.TimeSeconds) * time.Second,
+			Timeout:             time.Duration(conf.Keepalive.TimeoutSeconds) * time.Second,
+		},
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/flowshot-io/x/pkg/config"
+	"github.com/go-playground/validator/v10"
 )
 
 const (
@@ -24,13 +25,121 @@ type (
 		ConnectionString string `json:"connectionString"`
 	}
 
+	BlenderFarm struct {
+		MaxParallelBatches int `json:"maxParallelBatches" validate:"gte=0"`
+		HistoryThreshold   int `json:"historyThreshold" validate:"gte=0"`
+		// CacheRoutingTimeoutSeconds is how long batches wait for a node that has the project cached.
+		CacheRoutingTimeoutSeconds int `json:"cacheRoutingTimeoutSeconds" validate:"gte=0"`
+		// MaxBatchAttempts and MaxActivityAttempts cap the retries of transient failures.
+		MaxBatchAttempts    int `json:"maxBatchAttempts" validate:"gte=0"`
+		MaxActivityAttempts int `json:"maxActivityAttempts" validate:"gte=0"`
+	}
+
+	ArtifactCache struct {
+		Dir       string `json:"dir"`
+		MaxSizeMB int64  `json:"maxSizeMB" validate:"gte=0"`
+	}
+
+	Workspace struct {
+		Root      string `json:"root"`
+		MinFreeMB int64  `json:"minFreeMB" validate:"gte=0"`
+		// ReserveMB is reserved for projects that are not cached, as their size is not known before the download.
+		// The reservation is grown to fit the project once it is downloaded, before it is extracted.
+		ReserveMB int64 `json:"reserveMB" validate:"gte=0"`
+		// KeepFailedMinutes keeps the workspaces of failed renders for debugging.
+		KeepFailedMinutes int `json:"keepFailedMinutes" validate:"gte=0"`
+	}
+
+	Renderer struct {
+		// Default is the renderer used for jobs that do not choose one: rocketblend, blender or fake.
+		Default         string `json:"default"`
+		RocketblendPath string `json:"rocketblendPath"`
+		BlenderPath     string `json:"blenderPath"`
+		// FakeFrameTimeMs is how long the fake renderer takes per frame.
+		FakeFrameTimeMs int `json:"fakeFrameTimeMs" validate:"gte=0"`
+		// AllowBlankFrames accepts rendered frames that are fully black or transparent, such as the frames of a fade.
+		AllowBlankFrames bool `json:"allowBlankFrames"`
+	}
+
+	BlenderNode struct {
+		ArtifactCache ArtifactCache `json:"artifactCache"`
+		Workspace     Workspace     `json:"workspace"`
+		Renderer      Renderer      `json:"renderer"`
+	}
+
 	Global struct {
-		Temporal Temporal `json:"temporal"`
-		Storage  Storage  `json:"storage"`
+		Temporal    Temporal    `json:"temporal"`
+		Storage     Storage     `json:"storage"`
+		BlenderFarm BlenderFarm `json:"blenderFarm"`
+		BlenderNode BlenderNode `json:"blenderNode"`
+	}
+
+	APIKey struct {
+		// Name is the principal the key authenticates as.
+		Name string `json:"name"`
+		Key  string `json:"key"`
+	}
+
+	JWT struct {
+		HMACSecret string `json:"hmacSecret"`
+		JWKSFile   string `json:"jwksFile"`
+		Issuer     string `json:"issuer"`
+		Audience   string `json:"audience"`
+	}
+
+	// Auth configures how callers of the frontend authenticate. Authentication is
+	// disabled when neither API keys nor JWT verification are configured.
+	Auth struct {
+		APIKeys []APIKey `json:"apiKeys"`
+		JWT     JWT      `json:"jwt"`
+	}
+
+	// TLS configures the certificates a service listens with. The service listens
+	// in plaintext when no certificate is configured. Setting ClientCAFile requires
+	// clients to present a certificate signed by one of its CAs.
+	TLS struct {
+		CertFile     string `json:"certFile"`
+		KeyFile      string `json:"keyFile"`
+		ClientCAFile string `json:"clientCAFile"`
+		// ReloadIntervalSeconds is how often the files are checked for rotated certificates.
+		ReloadIntervalSeconds int `json:"reloadIntervalSeconds"`
+	}
+
+	Keepalive struct {
+		// MinTimeSeconds is the shortest interval clients may send keepalive pings at.
+		MinTimeSeconds      int  `json:"minTimeSeconds"`
+		PermitWithoutStream bool `json:"permitWithoutStream"`
+		// TimeSeconds and TimeoutSeconds control the pings the server sends to idle connections.
+		TimeSeconds    int `json:"timeSeconds"`
+		TimeoutSeconds int `json:"timeoutSeconds"`
+	}
+
+	// Listener configures where a network-facing service listens and the limits
+	// it puts on clients. Zero values keep the service and gRPC defaults.
+	Listener struct {
+		Address string `json:"address"`
+		Port    int    `json:"port"`
+		// UnixSocket listens on a Unix domain socket at this path instead of TCP.
+		UnixSocket           string    `json:"unixSocket"`
+		MaxRecvMsgSizeBytes  int       `json:"maxRecvMsgSizeBytes"`
+		MaxSendMsgSizeBytes  int       `json:"maxSendMsgSizeBytes"`
+		MaxConcurrentStreams uint32    `json:"maxConcurrentStreams"`
+		Keepalive            Keepalive `json:"keepalive"`
+	}
+
+	// Gateway configures the HTTP/JSON gateway of a service. Only the address, port,
+	// Unix socket and maximum received message size of its listener apply.
+	Gateway struct {
+		Enabled  bool     `json:"enabled"`
+		Listener Listener `json:"listener"`
 	}
 
 	// Service contains the service specific config items
 	Service struct {
+		Listener Listener `json:"listener"`
+		Gateway  Gateway  `json:"gateway"`
+		Auth     Auth     `json:"auth"`
+		TLS      TLS      `json:"tls"`
 	}
 )
 
@@ -45,7 +154,7 @@ func LoadConfig(configDir string) (*Config, error) {
 	return &conf, nil
 }
 
-// Validate validates this config
+// Validate validates this config against the validate tags of its fields
 func (c *Config) Validate() error {
-	return nil
+	return validator.New().Struct(c)
 }
@@ -0,0 +1,42 @@
+package config
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{name: "empty", config: Config{}},
+		{
+			name: "valid",
+			config: Config{Global: Global{
+				BlenderFarm: BlenderFarm{MaxParallelBatches: 4, HistoryThreshold: 1000},
+				BlenderNode: BlenderNode{Workspace: Workspace{MinFreeMB: 1024}},
+			}},
+		},
+		{
+			name:    "negative max parallel batches",
+			config:  Config{Global: Global{BlenderFarm: BlenderFarm{MaxParallelBatches: -1}}},
+			wantErr: true,
+		},
+		{
+			name:    "negative artifact cache size",
+			config:  Config{Global: Global{BlenderNode: BlenderNode{ArtifactCache: ArtifactCache{MaxSizeMB: -1}}}},
+			wantErr: true,
+		},
+		{
+			name:    "negative fake frame time",
+			config:  Config{Global: Global{BlenderNode: BlenderNode{Renderer: Renderer{FakeFrameTimeMs: -5}}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		err := tt.config.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%s) = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 type Factory interface {
 	TemporalClient(ctx context.Context, hostPort string) (client.Client, error)
 	ArtifactClient(ctx context.Context, connectionString string) (artifactservice.ArtifactServiceClient, error)
+	ArtifactStore(ctx context.Context, connectionString string) (types.Storage, error)
 }
 
 type RetryFactory struct {
@@ -72,6 +73,18 @@ func New(opts ...OptionsFunc) Factory {
 }
 
 func (f *RetryFactory) ArtifactClient(ctx context.Context, connectionString string) (artifactservice.ArtifactServiceClient, error) {
+	store, err := f.ArtifactStore(ctx, connectionString)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Artifact client: %v", err)
+	}
+
+	return artifactservice.New(artifactservice.Options{
+		Store: store,
+	})
+}
+
+// ArtifactStore returns the storage artifact clients created from connectionString keep artifacts in.
+func (f *RetryFactory) ArtifactStore(ctx context.Context, connectionString string) (types.Storage, error) {
 	var store types.Storage
 	err := f.retry(ctx, func() error {
 		var err error
@@ -80,12 +93,10 @@ func (f *RetryFactory) ArtifactClient(ctx context.Context, connectionString stri
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("unable to create Artifact client: %v", err)
+		return nil, fmt.Errorf("unable to create Artifact store: %v", err)
 	}
 
-	return artifactservice.New(artifactservice.Options{
-		Store: store,
-	})
+	return store, nil
 }
 
 func (f *RetryFactory) TemporalClient(ctx context.Context, hostPort string) (client.Client, error) {
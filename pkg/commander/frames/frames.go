@@ -0,0 +1,294 @@
+// Package frames parses and batches frame specs such as "1-10,15,20-100x5".
+package frames
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Range is an inclusive range of frames rendered every Step frames.
+	Range struct {
+		Start int
+		End   int
+		Step  int
+	}
+
+	// Spec is an ordered list of frame ranges.
+	Spec []Range
+)
+
+// Parse parses a comma separated list of frames and ranges. Each element is
+// either a single frame "15", a range "1-10" or a stepped range "20-100x5".
+func Parse(spec string) (Spec, error) {
+	var s Spec
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		r, err := parseRange(part)
+		if err != nil {
+			return nil, err
+		}
+
+		s = append(s, r)
+	}
+
+	if len(s) == 0 {
+		return nil, fmt.Errorf("frame spec %q is empty", spec)
+	}
+
+	return s, nil
+}
+
+// Contiguous returns a Spec covering every frame from start to end.
+func Contiguous(start int, end int) Spec {
+	return Spec{{Start: start, End: end, Step: 1}}
+}
+
+// FromFrames builds the shortest Spec of contiguous ranges for the given frames.
+func FromFrames(frames []int) Spec {
+	frames = unique(frames)
+
+	var s Spec
+	for _, frame := range frames {
+		if n := len(s); n > 0 && s[n-1].End+1 == frame {
+			s[n-1].End = frame
+			continue
+		}
+
+		s = append(s, Range{Start: frame, End: frame, Step: 1})
+	}
+
+	return s
+}
+
+// fromSorted builds a Spec of stepped ranges for frames that are sorted and without duplicates.
+// Two frames that are not adjacent are kept as single frames rather than a range of two.
+func fromSorted(frames []int) Spec {
+	var s Spec
+	for i := 0; i < len(frames); {
+		j, step := i+1, 1
+		if j < len(frames) {
+			step = frames[j] - frames[i]
+		}
+
+		for j < len(frames) && frames[j]-frames[j-1] == step {
+			j++
+		}
+
+		if j-i == 1 || (j-i == 2 && step != 1) {
+			s = append(s, Range{Start: frames[i], End: frames[i], Step: 1})
+			i++
+			continue
+		}
+
+		s = append(s, Range{Start: frames[i], End: frames[j-1], Step: step})
+		i = j
+	}
+
+	return s
+}
+
+// Frames returns every frame in the spec, sorted and without duplicates.
+func (s Spec) Frames() []int {
+	var frames []int
+	for _, r := range s {
+		if r.End < r.Start {
+			continue
+		}
+
+		// Stop before stepping past End instead of comparing after the step, which overflows near math.MaxInt.
+		step := uint64(r.step())
+		for frame := r.Start; ; frame += r.step() {
+			frames = append(frames, frame)
+			if uint64(r.End)-uint64(frame) < step {
+				break
+			}
+		}
+	}
+
+	return unique(frames)
+}
+
+// Len returns the number of distinct frames in the spec.
+func (s Spec) Len() int {
+	return len(s.Frames())
+}
+
+// Size returns the number of frames in the spec without expanding it. Frames in overlapping
+// ranges are counted once for every range, so Size is an upper bound of Len.
+func (s Spec) Size() int {
+	size := uint64(0)
+	for _, r := range s {
+		if r.End < r.Start {
+			continue
+		}
+
+		// The difference is taken unsigned, it overflows an int for ranges spanning most of its values.
+		frames := (uint64(r.End) - uint64(r.Start)) / uint64(r.step())
+		if frames >= math.MaxInt || size+frames+1 > math.MaxInt {
+			return math.MaxInt
+		}
+
+		size += frames + 1
+	}
+
+	return int(size)
+}
+
+// First returns the lowest frame in the spec.
+func (s Spec) First() int {
+	frames := s.Frames()
+	if len(frames) == 0 {
+		return 0
+	}
+
+	return frames[0]
+}
+
+// Last returns the highest frame in the spec.
+func (s Spec) Last() int {
+	frames := s.Frames()
+	if len(frames) == 0 {
+		return 0
+	}
+
+	return frames[len(frames)-1]
+}
+
+// Batches splits the frames in the spec into batches of at most size frames, keeping the step of
+// stepped ranges. A size of zero or less returns a single batch.
+func (s Spec) Batches(size int) []Spec {
+	frames := s.Frames()
+	if len(frames) == 0 {
+		return nil
+	}
+
+	if size <= 0 || size > len(frames) {
+		size = len(frames)
+	}
+
+	var batches []Spec
+	for start := 0; start < len(frames); start += size {
+		end := start + size
+		if end > len(frames) {
+			end = len(frames)
+		}
+
+		batches = append(batches, fromSorted(frames[start:end]))
+	}
+
+	return batches
+}
+
+// Runs returns the spec as contiguous ranges with a step of one.
+func (s Spec) Runs() Spec {
+	return FromFrames(s.Frames())
+}
+
+// String formats the spec in the syntax accepted by Parse.
+func (s Spec) String() string {
+	parts := make([]string, 0, len(s))
+	for _, r := range s {
+		parts = append(parts, r.String())
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// String formats the range in the syntax accepted by Parse.
+func (r Range) String() string {
+	switch {
+	case r.Start == r.End:
+		return strconv.Itoa(r.Start)
+	case r.step() == 1:
+		return fmt.Sprintf("%d-%d", r.Start, r.End)
+	default:
+		return fmt.Sprintf("%d-%dx%d", r.Start, r.End, r.Step)
+	}
+}
+
+func (r Range) step() int {
+	if r.Step <= 0 {
+		return 1
+	}
+
+	return r.Step
+}
+
+func parseRange(part string) (Range, error) {
+	r := Range{Step: 1}
+
+	rangePart := part
+	if i := strings.IndexByte(part, 'x'); i >= 0 {
+		step, err := strconv.Atoi(part[i+1:])
+		if err != nil || step <= 0 {
+			return Range{}, fmt.Errorf("invalid step in frame range %q", part)
+		}
+
+		r.Step = step
+		rangePart = part[:i]
+	}
+
+	// Search for the separator after the first character so negative start frames parse.
+	if i := strings.IndexByte(rangePart[min(1, len(rangePart)):], '-'); i >= 0 {
+		i += min(1, len(rangePart))
+
+		start, err := strconv.Atoi(rangePart[:i])
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid start in frame range %q", part)
+		}
+
+		end, err := strconv.Atoi(rangePart[i+1:])
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid end in frame range %q", part)
+		}
+
+		if end < start {
+			return Range{}, fmt.Errorf("frame range %q ends before it starts", part)
+		}
+
+		r.Start, r.End = start, end
+		return r, nil
+	}
+
+	if r.Step != 1 {
+		return Range{}, fmt.Errorf("step requires a frame range in %q", part)
+	}
+
+	frame, err := strconv.Atoi(rangePart)
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid frame %q", part)
+	}
+
+	r.Start, r.End = frame, frame
+	return r, nil
+}
+
+func unique(frames []int) []int {
+	sorted := append([]int(nil), frames...)
+	sort.Ints(sorted)
+
+	out := sorted[:0]
+	for i, frame := range sorted {
+		if i == 0 || frame != sorted[i-1] {
+			out = append(out, frame)
+		}
+	}
+
+	return out
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,161 @@
+package frames
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Spec
+	}{
+		{"15", Spec{{Start: 15, End: 15, Step: 1}}},
+		{"1-10", Spec{{Start: 1, End: 10, Step: 1}}},
+		{"20-100x5", Spec{{Start: 20, End: 100, Step: 5}}},
+		{"1-10, 15 ,20-100x5", Spec{{Start: 1, End: 10, Step: 1}, {Start: 15, End: 15, Step: 1}, {Start: 20, End: 100, Step: 5}}},
+		{"-5", Spec{{Start: -5, End: -5, Step: 1}}},
+		{"-5--1", Spec{{Start: -5, End: -1, Step: 1}}},
+		{"-10-10x5", Spec{{Start: -10, End: 10, Step: 5}}},
+		{"9223372036854775806-9223372036854775807x5", Spec{{Start: math.MaxInt - 1, End: math.MaxInt, Step: 5}}},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.spec, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		" , ",
+		"a",
+		"1-a",
+		"10-1",
+		"1-10x0",
+		"1-10x-2",
+		"1-10xa",
+		"5x2",
+		"9223372036854775808",
+	} {
+		if got, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", spec, got)
+		}
+	}
+}
+
+func TestFrames(t *testing.T) {
+	tests := []struct {
+		spec Spec
+		want []int
+	}{
+		{Contiguous(1, 5), []int{1, 2, 3, 4, 5}},
+		{Contiguous(7, 7), []int{7}},
+		{Contiguous(5, 1), nil},
+		{Spec{{Start: 1, End: 10, Step: 4}}, []int{1, 5, 9}},
+		{Spec{{Start: 1, End: 9, Step: 4}}, []int{1, 5, 9}},
+		{Spec{{Start: 1, End: 3, Step: 0}}, []int{1, 2, 3}},
+		{Spec{{Start: -3, End: 3, Step: 3}}, []int{-3, 0, 3}},
+		{Spec{{Start: 5, End: 8, Step: 1}, {Start: 1, End: 6, Step: 2}}, []int{1, 3, 5, 6, 7, 8}},
+		{Spec{{Start: math.MaxInt - 1, End: math.MaxInt, Step: 5}}, []int{math.MaxInt - 1}},
+		{Spec{{Start: math.MaxInt - 2, End: math.MaxInt, Step: 1}}, []int{math.MaxInt - 2, math.MaxInt - 1, math.MaxInt}},
+		{Spec{{Start: math.MaxInt - 4, End: math.MaxInt, Step: 2}}, []int{math.MaxInt - 4, math.MaxInt - 2, math.MaxInt}},
+		{Spec{{Start: math.MinInt, End: math.MinInt + 2, Step: 2}}, []int{math.MinInt, math.MinInt + 2}},
+		{Spec{{Start: 0, End: math.MaxInt, Step: math.MaxInt}}, []int{0, math.MaxInt}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.spec.Frames(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v.Frames() = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		spec Spec
+		want int
+	}{
+		{nil, 0},
+		{Contiguous(1, 10), 10},
+		{Contiguous(5, 1), 0},
+		{Spec{{Start: 1, End: 10000, Step: 5}}, 2000},
+		{Spec{{Start: -10, End: 10, Step: 5}}, 5},
+		{Spec{{Start: 1, End: 10, Step: 1}, {Start: 5, End: 15, Step: 1}}, 21},
+		{Spec{{Start: math.MaxInt - 1, End: math.MaxInt, Step: 5}}, 1},
+		{Spec{{Start: math.MinInt, End: math.MaxInt, Step: 1}}, math.MaxInt},
+		{Spec{{Start: 0, End: math.MaxInt, Step: 1}}, math.MaxInt},
+		{Spec{{Start: 1, End: math.MaxInt, Step: 1}, {Start: 1, End: 1, Step: 1}}, math.MaxInt},
+	}
+
+	for _, tt := range tests {
+		if got := tt.spec.Size(); got != tt.want {
+			t.Errorf("%v.Size() = %d, want %d", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestBatches(t *testing.T) {
+	tests := []struct {
+		spec Spec
+		size int
+		want []string
+	}{
+		{Contiguous(1, 10), 0, []string{"1-10"}},
+		{Contiguous(1, 10), 25, []string{"1-10"}},
+		{Contiguous(1, 10), 4, []string{"1-4", "5-8", "9-10"}},
+		{Contiguous(7, 7), 3, []string{"7"}},
+		{Contiguous(5, 1), 3, nil},
+		{Contiguous(-4, 1), 3, []string{"-4--2", "-1-1"}},
+		{Spec{{Start: 1, End: 5, Step: 1}, {Start: 3, End: 8, Step: 1}}, 4, []string{"1-4", "5-8"}},
+		{Spec{{Start: 1, End: 10000, Step: 5}}, 500, []string{"1-2496x5", "2501-4996x5", "5001-7496x5", "7501-9996x5"}},
+		{Spec{{Start: 1, End: 30, Step: 5}}, 4, []string{"1-16x5", "21,26"}},
+		{Spec{{Start: 1, End: 5, Step: 1}, {Start: 10, End: 30, Step: 10}}, 0, []string{"1-5,10-30x10"}},
+		{Spec{{Start: 1, End: 3, Step: 1}, {Start: 2, End: 14, Step: 4}}, 0, []string{"1-3,6-14x4"}},
+		{Spec{{Start: 1, End: 3, Step: 1}, {Start: 2, End: 10, Step: 4}}, 0, []string{"1-3,6,10"}},
+		{Spec{{Start: math.MinInt, End: math.MaxInt, Step: math.MaxInt}}, 2, []string{"-9223372036854775808,-1", "9223372036854775806"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, batch := range tt.spec.Batches(tt.size) {
+			got = append(got, batch.String())
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v.Batches(%d) = %v, want %v", tt.spec, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestStringParsesBack(t *testing.T) {
+	for _, spec := range []Spec{
+		Contiguous(1, 10),
+		Contiguous(7, 7),
+		Contiguous(-5, -1),
+		Contiguous(-1, 1),
+		{{Start: 20, End: 100, Step: 5}},
+		{{Start: -10, End: 10, Step: 5}},
+		{{Start: 1, End: 10, Step: 1}, {Start: 15, End: 15, Step: 1}, {Start: 20, End: 100, Step: 5}},
+		{{Start: math.MinInt, End: math.MaxInt, Step: math.MaxInt}},
+	} {
+		got, err := Parse(spec.String())
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", spec.String(), err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, spec) {
+			t.Errorf("Parse(%q) = %v, want %v", spec.String(), got, spec)
+		}
+	}
+}
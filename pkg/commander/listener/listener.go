@@ -0,0 +1,107 @@
+// Package listener opens the network listener of a gRPC service and builds the
+// server options that limit what its clients may do.
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+type (
+	// Options configures a listener. Zero values keep the gRPC defaults.
+	Options struct {
+		// Address is the interface to bind to. All interfaces are used when empty.
+		Address string
+		Port    int
+		// UnixSocket listens on a Unix domain socket at this path instead of TCP.
+		UnixSocket string
+		// MaxRecvMsgSize and MaxSendMsgSize are the largest messages in bytes the server accepts and sends.
+		MaxRecvMsgSize int
+		MaxSendMsgSize int
+		// MaxConcurrentStreams caps the concurrent calls on one client connection.
+		MaxConcurrentStreams uint32
+		Keepalive            Keepalive
+	}
+
+	Keepalive struct {
+		// MinTime is the shortest interval clients may send keepalive pings at. Clients that ping more often are disconnected.
+		MinTime time.Duration
+		// PermitWithoutStream allows clients to ping while they have no calls in flight.
+		PermitWithoutStream bool
+		// Time is how long a connection may be idle before the server pings the client.
+		Time time.Duration
+		// Timeout is how long the server waits for the ping to be acknowledged before closing the connection.
+		Timeout time.Duration
+	}
+)
+
+// String returns the address the listener binds to.
+func (o Options) String() string {
+	if o.UnixSocket != "" {
+		return "unix://" + o.UnixSocket
+	}
+
+	return net.JoinHostPort(o.Address, strconv.Itoa(o.Port))
+}
+
+// Listen opens the listener. A socket file left behind by a previous run is removed first.
+func Listen(opts Options) (net.Listener, error) {
+	if opts.UnixSocket == "" {
+		return net.Listen("tcp", opts.String())
+	}
+
+	info, err := os.Stat(opts.UnixSocket)
+	switch {
+	case err == nil && info.Mode()&fs.ModeSocket == 0:
+		return nil, fmt.Errorf("%s exists and is not a socket", opts.UnixSocket)
+	case err == nil:
+		if err := os.Remove(opts.UnixSocket); err != nil {
+			return nil, fmt.Errorf("unable to remove stale socket: %w", err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+
+	return net.Listen("unix", opts.UnixSocket)
+}
+
+// ServerOptions returns the gRPC server options that apply the limits in opts.
+func ServerOptions(opts Options) []grpc.ServerOption {
+	var serverOptions []grpc.ServerOption
+
+	if opts.MaxRecvMsgSize > 0 {
+		serverOptions = append(serverOptions, grpc.MaxRecvMsgSize(opts.MaxRecvMsgSize))
+	}
+
+	if opts.MaxSendMsgSize > 0 {
+		serverOptions = append(serverOptions, grpc.MaxSendMsgSize(opts.MaxSendMsgSize))
+	}
+
+	if opts.MaxConcurrentStreams > 0 {
+		serverOptions = append(serverOptions, grpc.MaxConcurrentStreams(opts.MaxConcurrentStreams))
+	}
+
+	if opts.Keepalive.MinTime > 0 || opts.Keepalive.PermitWithoutStream {
+		serverOptions = append(serverOptions, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             opts.Keepalive.MinTime,
+			PermitWithoutStream: opts.Keepalive.PermitWithoutStream,
+		}))
+	}
+
+	if opts.Keepalive.Time > 0 || opts.Keepalive.Timeout > 0 {
+		serverOptions = append(serverOptions, grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    opts.Keepalive.Time,
+			Timeout: opts.Keepalive.Timeout,
+		}))
+	}
+
+	return serverOptions
+}
@@ -0,0 +1,142 @@
+// Package noderegistry tracks which blendernode hosts are online and which
+// project artifacts they have cached, so batches can be routed to a host that
+// does not need to download the project first.
+//
+// The registry is a single long running workflow. Nodes report themselves to
+// it with a signal, which starts the workflow if it is not running yet. As it
+// never stops, changes to RegistryWorkflow need a workflow.GetVersion gate
+// like the render workflows, and are replayed against testdata/histories/noderegistry.
+package noderegistry
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	WorkflowID   = "blendernode-registry"
+	WorkflowType = "NodeRegistryWorkflow"
+	ReportSignal = "noderegistry-report"
+	Query        = "noderegistry-query"
+
+	// TTL is how long a node is kept in the registry after its last report.
+	TTL = 2 * time.Minute
+
+	// maxReports bounds the history of a single run of the registry.
+	maxReports = 1000
+)
+
+type (
+	// Node is the state a blendernode host reports to the registry.
+	Node struct {
+		Host string
+		// Queue is the task queue only this host polls.
+		Queue     string
+		Artifacts []string
+		// ReportedAt is set by the registry when it receives the report.
+		ReportedAt time.Time
+	}
+
+	RegistryWorkflowInput struct {
+		// Nodes carries the registered nodes across ContinueAsNew.
+		Nodes map[string]Node
+	}
+)
+
+func RegistryWorkflow(ctx workflow.Context, request RegistryWorkflowInput) error {
+	nodes := request.Nodes
+	if nodes == nil {
+		nodes = map[string]Node{}
+	}
+
+	err := workflow.SetQueryHandler(ctx, Query, func() ([]Node, error) {
+		return sortedNodes(nodes), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	reports := workflow.GetSignalChannel(ctx, ReportSignal)
+	for i := 0; i < maxReports; i++ {
+		var node Node
+		reports.Receive(ctx, &node)
+
+		now := workflow.Now(ctx)
+		node.ReportedAt = now
+		nodes[node.Host] = node
+
+		for host, n := range nodes {
+			if now.Sub(n.ReportedAt) > TTL {
+				delete(nodes, host)
+			}
+		}
+	}
+
+	// Apply any reports that arrived in the meantime so none are lost by continuing as new.
+	for {
+		var node Node
+		if !reports.ReceiveAsync(&node) {
+			break
+		}
+
+		node.ReportedAt = workflow.Now(ctx)
+		nodes[node.Host] = node
+	}
+
+	return workflow.NewContinueAsNewError(ctx, WorkflowType, RegistryWorkflowInput{Nodes: nodes})
+}
+
+// Report registers node with the registry, starting the registry on taskQueue if it is not running.
+func Report(ctx context.Context, c client.Client, taskQueue string, node Node) error {
+	options := client.StartWorkflowOptions{
+		ID:        WorkflowID,
+		TaskQueue: taskQueue,
+	}
+
+	_, err := c.SignalWithStartWorkflow(ctx, WorkflowID, ReportSignal, node, options, WorkflowType, RegistryWorkflowInput{})
+	return err
+}
+
+// Nodes returns the nodes that reported to the registry within TTL.
+func Nodes(ctx context.Context, c client.Client) ([]Node, error) {
+	response, err := c.QueryWorkflow(ctx, WorkflowID, "", Query)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []Node
+	if err := response.Get(&nodes); err != nil {
+		return nil, err
+	}
+
+	online := nodes[:0]
+	for _, node := range nodes {
+		if time.Since(node.ReportedAt) <= TTL {
+			online = append(online, node)
+		}
+	}
+
+	return online, nil
+}
+
+// sortedNodes returns the nodes most recently reported first.
+func sortedNodes(nodes map[string]Node) []Node {
+	sorted := make([]Node, 0, len(nodes))
+	for _, node := range nodes {
+		sorted = append(sorted, node)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].ReportedAt.Equal(sorted[j].ReportedAt) {
+			return sorted[i].ReportedAt.After(sorted[j].ReportedAt)
+		}
+
+		return sorted[i].Host < sorted[j].Host
+	})
+
+	return sorted
+}
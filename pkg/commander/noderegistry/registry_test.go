@@ -0,0 +1,126 @@
+package noderegistry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+type WorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(WorkflowTestSuite))
+}
+
+func (s *WorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterWorkflowWithOptions(RegistryWorkflow, workflow.RegisterOptions{Name: WorkflowType})
+}
+
+// report signals node to the registry after delay.
+func (s *WorkflowTestSuite) report(delay time.Duration, node Node) {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(ReportSignal, node)
+	}, delay)
+}
+
+// query returns the hosts of the registered nodes after delay.
+func (s *WorkflowTestSuite) query(delay time.Duration, hosts *[]string) {
+	s.env.RegisterDelayedCallback(func() {
+		response, err := s.env.QueryWorkflow(Query)
+		s.NoError(err)
+
+		var nodes []Node
+		s.NoError(response.Get(&nodes))
+
+		*hosts = nil
+		for _, node := range nodes {
+			*hosts = append(*hosts, node.Host)
+		}
+	}, delay)
+}
+
+// continued returns the input the registry continued as new with.
+func (s *WorkflowTestSuite) continued() RegistryWorkflowInput {
+	s.True(s.env.IsWorkflowCompleted())
+
+	var canErr *workflow.ContinueAsNewError
+	s.Require().True(errors.As(s.env.GetWorkflowError(), &canErr))
+	s.Equal(WorkflowType, canErr.WorkflowType.Name)
+
+	var request RegistryWorkflowInput
+	s.NoError(converter.GetDefaultDataConverter().FromPayloads(canErr.Input, &request))
+	return request
+}
+
+func (s *WorkflowTestSuite) TestReportAndQuery() {
+	var first, second []string
+	s.report(time.Second, Node{Host: "node-a", Queue: "node-a", Artifacts: []string{"project"}})
+	s.report(2*time.Second, Node{Host: "node-b", Queue: "node-b"})
+	s.query(3*time.Second, &first)
+	s.report(4*time.Second, Node{Host: "node-a", Queue: "node-a", Artifacts: []string{"project", "other"}})
+	s.query(5*time.Second, &second)
+	s.env.RegisterDelayedCallback(s.env.CancelWorkflow, 6*time.Second)
+
+	s.env.ExecuteWorkflow(WorkflowType, RegistryWorkflowInput{})
+
+	// The nodes most recently reported are listed first.
+	s.Equal([]string{"node-b", "node-a"}, first)
+	s.Equal([]string{"node-a", "node-b"}, second)
+}
+
+func (s *WorkflowTestSuite) TestExpiredNodesAreRemoved() {
+	var hosts []string
+	s.report(time.Second, Node{Host: "node-a"})
+	s.report(2*time.Second, Node{Host: "node-b"})
+	s.report(TTL+90*time.Second, Node{Host: "node-b"})
+	s.query(TTL+91*time.Second, &hosts)
+	s.env.RegisterDelayedCallback(s.env.CancelWorkflow, TTL+92*time.Second)
+
+	s.env.ExecuteWorkflow(WorkflowType, RegistryWorkflowInput{})
+
+	s.Equal([]string{"node-b"}, hosts)
+}
+
+func (s *WorkflowTestSuite) TestContinueAsNewCarriesNodes() {
+	// node-a reports once and node-b until the run has seen maxReports reports, and once more
+	// while the run continues as new.
+	s.report(time.Second, Node{Host: "node-a", Queue: "node-a", Artifacts: []string{"project"}})
+	s.env.RegisterDelayedCallback(func() {
+		for i := 0; i < maxReports; i++ {
+			s.env.SignalWorkflow(ReportSignal, Node{Host: "node-b", Queue: "node-b"})
+		}
+	}, 2*time.Second)
+
+	s.env.ExecuteWorkflow(WorkflowType, RegistryWorkflowInput{})
+
+	request := s.continued()
+	s.Len(request.Nodes, 2)
+	s.Equal([]string{"project"}, request.Nodes["node-a"].Artifacts)
+	s.Equal("node-b", request.Nodes["node-b"].Queue)
+	s.False(request.Nodes["node-a"].ReportedAt.IsZero())
+}
+
+func (s *WorkflowTestSuite) TestCarriedNodesAreRegistered() {
+	var hosts []string
+	reportedAt := time.Now()
+	s.env.SetStartTime(reportedAt.Add(time.Second))
+	s.query(time.Second, &hosts)
+	s.env.RegisterDelayedCallback(s.env.CancelWorkflow, 2*time.Second)
+
+	s.env.ExecuteWorkflow(WorkflowType, RegistryWorkflowInput{Nodes: map[string]Node{
+		"node-a": {Host: "node-a", Queue: "node-a", ReportedAt: reportedAt},
+	}})
+
+	s.Equal([]string{"node-a"}, hosts)
+}
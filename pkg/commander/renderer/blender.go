@@ -0,0 +1,52 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+type blender struct {
+	path string
+}
+
+func (b *blender) Render(ctx context.Context, dir string, start int, end int, log io.Writer) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	project, err := findProject(dir)
+	if err != nil {
+		return err
+	}
+
+	// The frame range and output path are only applied to the animation when they come before -a.
+	args := []string{
+		"-b", project,
+		"-o", filepath.Join(dir, OutputDir, "####"),
+		"-s", fmt.Sprintf("%d", start),
+		"-e", fmt.Sprintf("%d", end),
+		"-a",
+	}
+
+	return run(ctx, b.path, args, log)
+}
+
+// findProject returns the single .blend file at the top of dir.
+func findProject(dir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.blend"))
+	if err != nil {
+		return "", err
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: no .blend file found in %s", ErrInvalidProject, dir)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%w: more than one .blend file found in %s", ErrInvalidProject, dir)
+	}
+}
@@ -0,0 +1,74 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fake writes a small grey PNG for every frame, the shade depending only on the
+// frame number, so the same frames always produce the same files. No shade is
+// black, so the frames pass output validation.
+type fake struct {
+	frameTime time.Duration
+}
+
+func (f *fake) Render(ctx context.Context, dir string, start int, end int, log io.Writer) error {
+	outputDir := filepath.Join(dir, OutputDir)
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return err
+	}
+
+	for frame := start; frame <= end; frame++ {
+		fmt.Fprintf(log, "Fra:%d Mem:1.00M (Peak 1.00M) | Time:00:00.00 | Mem:0.00M, Peak:0.00M | Scene, ViewLayer | Sample 1/1\n", frame)
+
+		if f.frameTime > 0 {
+			timer := time.NewTimer(f.frameTime)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path := filepath.Join(outputDir, fmt.Sprintf("%04d.png", frame))
+		if err := writeFrame(path, frame); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(log, "Saved: '%s'\n", path)
+	}
+
+	return nil
+}
+
+func writeFrame(path string, frame int) error {
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	shade := color.Gray{Y: uint8(frame%255 + 1)}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.SetGray(x, y, shade)
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
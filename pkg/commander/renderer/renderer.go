@@ -0,0 +1,120 @@
+// Package renderer provides the backends a blendernode renders projects with.
+//
+// Every backend renders a range of frames of the project in a directory into
+// its OutputDir and writes a Blender style render log, which is what
+// renderlog reads the progress of a render from.
+package renderer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/flowshot-io/commander/pkg/commander/supervisor"
+)
+
+const (
+	// Rocketblend renders with "rocketblend render", which resolves the Blender build and add-ons of the project.
+	Rocketblend = "rocketblend"
+	// Blender renders the .blend file of the project with "blender -b".
+	Blender = "blender"
+	// Fake writes placeholder frames without rendering anything.
+	Fake = "fake"
+
+	// Default is the renderer used when none is configured.
+	Default = Rocketblend
+
+	// OutputDir is the directory, relative to the project directory, the frames are written to.
+	OutputDir = "output"
+)
+
+var (
+	// Names lists the renderers New creates.
+	Names = []string{Rocketblend, Blender, Fake}
+
+	// ErrInvalidProject is returned when the project directory does not hold a project the renderer can open.
+	ErrInvalidProject = errors.New("invalid project")
+)
+
+type (
+	// Renderer renders the frames start to end of the project in dir into
+	// OutputDir, writing its render log to log. It stops and returns once ctx is done.
+	Renderer interface {
+		Render(ctx context.Context, dir string, start int, end int, log io.Writer) error
+	}
+
+	Options struct {
+		// RocketblendPath and BlenderPath are the executables run by the rocketblend
+		// and blender renderers. They are looked up on PATH by default.
+		RocketblendPath string
+		BlenderPath     string
+		// FakeFrameTime is how long the fake renderer takes per frame.
+		FakeFrameTime time.Duration
+	}
+)
+
+// New returns the renderer called name.
+func New(name string, opts Options) (Renderer, error) {
+	switch name {
+	case Rocketblend:
+		if opts.RocketblendPath == "" {
+			opts.RocketblendPath = "rocketblend"
+		}
+
+		return &rocketblend{path: opts.RocketblendPath}, nil
+	case Blender:
+		if opts.BlenderPath == "" {
+			opts.BlenderPath = "blender"
+		}
+
+		return &blender{path: opts.BlenderPath}, nil
+	case Fake:
+		return &fake{frameTime: opts.FakeFrameTime}, nil
+	default:
+		return nil, fmt.Errorf("unknown renderer: %q", name)
+	}
+}
+
+// Valid reports whether name is a renderer New creates.
+func Valid(name string) bool {
+	for _, n := range Names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// run runs a renderer process under a supervisor, copying its output to log until it exits.
+func run(ctx context.Context, name string, args []string, log io.Writer) error {
+	proc, err := supervisor.Start(supervisor.Options{
+		Name: name,
+		Args: args,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(log, "Running: %s\n", proc.String())
+
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		defer proc.Output().Close()
+
+		_, _ = io.Copy(log, proc.Output())
+	}()
+
+	select {
+	case <-ctx.Done():
+		_ = proc.Stop()
+		<-copied
+		return ctx.Err()
+	case <-proc.Done():
+		<-copied
+		return proc.Err()
+	}
+}
@@ -0,0 +1,22 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+type rocketblend struct {
+	path string
+}
+
+func (r *rocketblend) Render(ctx context.Context, dir string, start int, end int, log io.Writer) error {
+	args := []string{
+		"render",
+		"-d", dir,
+		"-s", fmt.Sprintf("%d", start),
+		"-e", fmt.Sprintf("%d", end),
+	}
+
+	return run(ctx, r.path, args, log)
+}
@@ -0,0 +1,96 @@
+package renderlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// Dir is the directory, relative to the workspace of a render, its log is kept in.
+	Dir = ".render-log"
+	// FileName is the name of the render log in Dir and in the archived log artifact.
+	FileName = "render.log"
+)
+
+// Capture keeps the log of a render. The log is written to a file until it reaches
+// its size limit, and the last lines are kept in memory for error messages. It is
+// not safe for concurrent use.
+type Capture struct {
+	file      *os.File
+	maxSize   int64
+	size      int64
+	truncated bool
+
+	tail     []string
+	tailNext int
+	tailFull bool
+}
+
+// NewCapture appends to the log file at path, so retries of a render add to the
+// same log, keeping it under maxSize bytes and the last tailLines lines in memory.
+func NewCapture(path string, maxSize int64, tailLines int) (*Capture, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Capture{
+		file:      file,
+		maxSize:   maxSize,
+		size:      info.Size(),
+		truncated: info.Size() >= maxSize,
+		tail:      make([]string, tailLines),
+	}, nil
+}
+
+// Line adds a line to the log. The file is no longer written to once writing it fails.
+func (c *Capture) Line(line string) error {
+	if len(c.tail) > 0 {
+		c.tail[c.tailNext] = line
+		c.tailNext = (c.tailNext + 1) % len(c.tail)
+		c.tailFull = c.tailFull || c.tailNext == 0
+	}
+
+	if c.truncated {
+		return nil
+	}
+
+	if c.size+int64(len(line))+1 > c.maxSize {
+		c.truncated = true
+		_, err := fmt.Fprintf(c.file, "[render log truncated at %d bytes]\n", c.size)
+		return err
+	}
+
+	n, err := fmt.Fprintln(c.file, line)
+	c.size += int64(n)
+	if err != nil {
+		c.truncated = true
+	}
+
+	return err
+}
+
+// Tail returns the last lines added to the log, oldest first.
+func (c *Capture) Tail() []string {
+	if !c.tailFull {
+		return append([]string(nil), c.tail[:c.tailNext]...)
+	}
+
+	return append(append([]string(nil), c.tail[c.tailNext:]...), c.tail[:c.tailNext]...)
+}
+
+// Close closes the log file.
+func (c *Capture) Close() error {
+	return c.file.Close()
+}
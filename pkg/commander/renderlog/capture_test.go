@@ -0,0 +1,124 @@
+package renderlog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var cyclesLines = []string{
+	"Fra:1 Mem:88.94M (Peak 95.12M) | Time:00:00.40 | Remaining:00:01.52 | Mem:2.01M, Peak:2.01M | Scene, ViewLayer | Sample 1/128",
+	"Fra:1 Mem:88.94M (Peak 95.12M) | Time:00:02.27 | Mem:2.01M, Peak:2.01M | Scene, ViewLayer | Sample 128/128",
+	"Saved: '/work/output/0001.png'",
+	" Time: 00:02.45 (Saving: 00:00.03)",
+}
+
+func TestCapture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), Dir, FileName)
+	capture, err := NewCapture(path, 1<<20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range cyclesLines {
+		if err := capture.Line(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := capture.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := capture.Tail(), cyclesLines[2:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tail() = %q, want %q", got, want)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := strings.Join(cyclesLines, "\n") + "\n"; string(data) != want {
+		t.Errorf("log = %q, want %q", data, want)
+	}
+}
+
+func TestCaptureTailBeforeFull(t *testing.T) {
+	capture, err := NewCapture(filepath.Join(t.TempDir(), FileName), 1<<20, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer capture.Close()
+
+	capture.Line(cyclesLines[0])
+	capture.Line(cyclesLines[1])
+
+	if got := capture.Tail(); !reflect.DeepEqual(got, cyclesLines[:2]) {
+		t.Errorf("Tail() = %q, want %q", got, cyclesLines[:2])
+	}
+}
+
+func TestCaptureTruncates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	maxSize := int64(len(cyclesLines[0]) + 1 + len(cyclesLines[1]))
+
+	capture, err := NewCapture(path, maxSize, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range cyclesLines {
+		if err := capture.Line(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	capture.Close()
+
+	// The tail keeps following the render after the file stops growing.
+	if got := capture.Tail(); !reflect.DeepEqual(got, cyclesLines[3:]) {
+		t.Errorf("Tail() = %q, want %q", got, cyclesLines[3:])
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := cyclesLines[0] + "\n[render log truncated at " + strconv.Itoa(len(cyclesLines[0])+1) + " bytes]\n"
+	if string(data) != want {
+		t.Errorf("log = %q, want %q", data, want)
+	}
+}
+
+func TestCaptureAppends(t *testing.T) {
+	// Retries of a render add to the log of the earlier attempts, within the same size limit.
+	path := filepath.Join(t.TempDir(), FileName)
+	maxSize := int64(len(cyclesLines[0]) + 1)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		capture, err := NewCapture(path, maxSize, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		capture.Line(cyclesLines[0])
+		capture.Close()
+
+		if got := capture.Tail(); len(got) != 0 {
+			t.Errorf("Tail() = %q without tail lines, want none", got)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := cyclesLines[0] + "\n"; string(data) != want {
+		t.Errorf("log = %q, want %q", data, want)
+	}
+}
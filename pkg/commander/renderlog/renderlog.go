@@ -0,0 +1,183 @@
+// Package renderlog follows the output of a Blender render and tracks its progress.
+//
+// It understands the status lines Blender prints while rendering, such as
+//
+//	Fra:1 Mem:67.07M (Peak 67.07M) | Time:00:00.33 | Mem:0.00M, Peak:0.00M | Scene, ViewLayer | Sample 12/128
+//	Fra:1 Mem:120.33M (Peak 140.12M) | Time:00:00.45 | Rendering 5 / 64 samples
+//	Saved: '/tmp/output/0001.png'
+package renderlog
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	frameRe  = regexp.MustCompile(`^Fra:(-?\d+)\b`)
+	memRe    = regexp.MustCompile(`\bMem:\s*([\d.]+)([KMG])\s*\(Peak\s+([\d.]+)([KMG])\)`)
+	sampleRe = regexp.MustCompile(`\bSample (\d+)/(\d+)\b`)
+	eeveeRe  = regexp.MustCompile(`\bRendering (\d+) / (\d+) samples\b`)
+	savedRe  = regexp.MustCompile(`^Saved: '?(.*?)'?$`)
+	memUnits = map[string]float64{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30}
+)
+
+type (
+	// FrameTime is how long a single frame took to render.
+	FrameTime struct {
+		Frame    int
+		Duration time.Duration
+	}
+
+	// SavedFrame is a frame the render has written to disk.
+	SavedFrame struct {
+		Frame  int
+		Output string
+	}
+
+	// Progress is the state of a render as read from its log.
+	Progress struct {
+		CurrentFrame int
+		// FramesCompleted is the number of distinct frames saved. Blender saves a file for every
+		// output of a frame, and a retried render may save a frame again, so it can be less than
+		// the number of Saved files.
+		FramesCompleted int
+		FramesTotal     int
+		Sample          int
+		Samples         int
+		// Memory and PeakMemory are in bytes.
+		Memory     int64
+		PeakMemory int64
+		FrameTimes []FrameTime
+		Saved      []SavedFrame
+		// LastOutput is the file saved for the last completed frame.
+		LastOutput string
+		UpdatedAt  time.Time
+	}
+
+	// Parser builds a Progress from the lines of a render log. It is not safe for concurrent use.
+	Parser struct {
+		progress       Progress
+		frameStartedAt time.Time
+		rendering      bool
+		// started is set once a status line named the frame being rendered.
+		started   bool
+		completed map[int]bool
+	}
+)
+
+// NewParser returns a Parser for a render of framesTotal frames.
+func NewParser(framesTotal int) *Parser {
+	return &Parser{
+		progress:  Progress{FramesTotal: framesTotal},
+		completed: map[int]bool{},
+	}
+}
+
+// Parse updates the progress from a single log line and reports whether it changed.
+func (p *Parser) Parse(line string) bool {
+	line = strings.TrimSpace(line)
+	now := time.Now()
+
+	if m := savedRe.FindStringSubmatch(line); m != nil {
+		p.progress.LastOutput = m[1]
+		if p.rendering {
+			p.progress.FrameTimes = append(p.progress.FrameTimes, FrameTime{
+				Frame:    p.progress.CurrentFrame,
+				Duration: now.Sub(p.frameStartedAt),
+			})
+		}
+
+		// Every file saved after a frame, such as the other outputs of a frame, belongs to the last frame rendered.
+		if p.started {
+			p.progress.Saved = append(p.progress.Saved, SavedFrame{
+				Frame:  p.progress.CurrentFrame,
+				Output: m[1],
+			})
+			p.completed[p.progress.CurrentFrame] = true
+			p.progress.FramesCompleted = len(p.completed)
+		}
+
+		p.rendering = false
+		p.progress.UpdatedAt = now
+		return true
+	}
+
+	m := frameRe.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
+
+	frame, _ := strconv.Atoi(m[1])
+	if !p.rendering || frame != p.progress.CurrentFrame {
+		p.progress.CurrentFrame = frame
+		p.progress.Sample, p.progress.Samples = 0, 0
+		p.frameStartedAt = now
+		p.rendering = true
+		p.started = true
+	}
+
+	if m := memRe.FindStringSubmatch(line); m != nil {
+		p.progress.Memory = parseMemory(m[1], m[2])
+		if peak := parseMemory(m[3], m[4]); peak > p.progress.PeakMemory {
+			p.progress.PeakMemory = peak
+		}
+	}
+
+	if m := sampleRe.FindStringSubmatch(line); m != nil {
+		p.progress.Sample, _ = strconv.Atoi(m[1])
+		p.progress.Samples, _ = strconv.Atoi(m[2])
+	} else if m := eeveeRe.FindStringSubmatch(line); m != nil {
+		p.progress.Sample, _ = strconv.Atoi(m[1])
+		p.progress.Samples, _ = strconv.Atoi(m[2])
+	}
+
+	p.progress.UpdatedAt = now
+	return true
+}
+
+// Resume continues from the progress of an earlier render of the same frames. The frames
+// completed are counted again from the frames of progress.Saved.
+func (p *Parser) Resume(progress Progress) {
+	p.completed = map[int]bool{}
+	for _, frame := range progress.Saved {
+		p.completed[frame.Frame] = true
+	}
+
+	progress.FramesTotal = p.progress.FramesTotal
+	progress.FramesCompleted = len(p.completed)
+	p.progress = progress
+	p.rendering = false
+}
+
+// Progress returns a copy of the current progress.
+func (p *Parser) Progress() Progress {
+	progress := p.progress
+	progress.FrameTimes = append([]FrameTime(nil), p.progress.FrameTimes...)
+	progress.Saved = append([]SavedFrame(nil), p.progress.Saved...)
+	return progress
+}
+
+// AverageFrameTime returns the mean time taken by the completed frames.
+func (p Progress) AverageFrameTime() time.Duration {
+	if len(p.FrameTimes) == 0 {
+		return 0
+	}
+
+	var total time.Duration
+	for _, ft := range p.FrameTimes {
+		total += ft.Duration
+	}
+
+	return total / time.Duration(len(p.FrameTimes))
+}
+
+func parseMemory(value string, unit string) int64 {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+
+	return int64(v * memUnits[unit])
+}
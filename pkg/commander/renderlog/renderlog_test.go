@@ -0,0 +1,152 @@
+package renderlog
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// parseFile feeds every line of a render log in testdata to parser.
+func parseFile(t *testing.T, parser *Parser, name string) {
+	t.Helper()
+
+	file, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parser.Parse(scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func savedFrames(progress Progress) []int {
+	var frames []int
+	for _, saved := range progress.Saved {
+		frames = append(frames, saved.Frame)
+	}
+
+	return frames
+}
+
+func frameTimes(progress Progress) []int {
+	var frames []int
+	for _, ft := range progress.FrameTimes {
+		frames = append(frames, ft.Frame)
+	}
+
+	return frames
+}
+
+func TestParseCycles(t *testing.T) {
+	parser := NewParser(3)
+	parseFile(t, parser, "cycles.log")
+	progress := parser.Progress()
+
+	if progress.FramesCompleted != 2 || progress.FramesTotal != 3 {
+		t.Errorf("frames completed = %d of %d, want 2 of 3", progress.FramesCompleted, progress.FramesTotal)
+	}
+
+	if progress.CurrentFrame != 3 || progress.Sample != 57 || progress.Samples != 128 {
+		t.Errorf("frame %d sample %d/%d, want frame 3 sample 57/128", progress.CurrentFrame, progress.Sample, progress.Samples)
+	}
+
+	if want := int64(1288490188); progress.Memory != want {
+		t.Errorf("Memory = %d, want %d", progress.Memory, want)
+	}
+
+	if want := int64(1610612736); progress.PeakMemory != want {
+		t.Errorf("PeakMemory = %d, want %d", progress.PeakMemory, want)
+	}
+
+	want := []SavedFrame{{Frame: 1, Output: "/work/output/0001.png"}, {Frame: 2, Output: "/work/output/0002.png"}}
+	if !reflect.DeepEqual(progress.Saved, want) {
+		t.Errorf("Saved = %v, want %v", progress.Saved, want)
+	}
+
+	if got := frameTimes(progress); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("FrameTimes frames = %v, want [1 2]", got)
+	}
+
+	if progress.LastOutput != "/work/output/0002.png" {
+		t.Errorf("LastOutput = %q, want %q", progress.LastOutput, "/work/output/0002.png")
+	}
+}
+
+func TestParseCountsDistinctFrames(t *testing.T) {
+	// Stereo renders save a file for each view, and the log ends with frame 10 rendered again.
+	parser := NewParser(2)
+	parseFile(t, parser, "eevee-stereo.log")
+	progress := parser.Progress()
+
+	if progress.FramesCompleted != 2 {
+		t.Errorf("FramesCompleted = %d, want 2", progress.FramesCompleted)
+	}
+
+	if got := savedFrames(progress); !reflect.DeepEqual(got, []int{10, 10, 11, 11, 10, 10}) {
+		t.Errorf("Saved frames = %v, want every saved file", got)
+	}
+
+	if got := frameTimes(progress); !reflect.DeepEqual(got, []int{10, 11, 10}) {
+		t.Errorf("FrameTimes frames = %v, want one per render of a frame", got)
+	}
+
+	if progress.Sample != 64 || progress.Samples != 64 {
+		t.Errorf("sample %d/%d, want 64/64", progress.Sample, progress.Samples)
+	}
+}
+
+func TestParseIgnoresOtherLines(t *testing.T) {
+	parser := NewParser(1)
+	for _, line := range []string{
+		"Blender 3.5.1 (hash e1ccd9d4a1d3 built 2023-04-24 23:31:15)",
+		"Read blend: /work/project.blend",
+		" Time: 00:02.45 (Saving: 00:00.03)",
+		"",
+	} {
+		if parser.Parse(line) {
+			t.Errorf("Parse(%q) = true, want false", line)
+		}
+	}
+
+	// A file saved before any frame was rendered is not a rendered frame.
+	parser.Parse("Saved: '/work/output/preview.png'")
+	if progress := parser.Progress(); progress.FramesCompleted != 0 || len(progress.Saved) != 0 {
+		t.Errorf("progress = %+v, want no frames completed", progress)
+	}
+}
+
+func TestResumeCountsDistinctFrames(t *testing.T) {
+	parser := NewParser(3)
+	parser.Resume(Progress{
+		FramesTotal:     10,
+		FramesCompleted: 4,
+		Saved: []SavedFrame{
+			{Frame: 1, Output: "/work/output/0001_L.png"},
+			{Frame: 1, Output: "/work/output/0001_R.png"},
+			{Frame: 2, Output: "/work/output/0002_L.png"},
+			{Frame: 2, Output: "/work/output/0002_R.png"},
+		},
+	})
+
+	if progress := parser.Progress(); progress.FramesCompleted != 2 || progress.FramesTotal != 3 {
+		t.Errorf("frames completed = %d of %d, want 2 of 3", progress.FramesCompleted, progress.FramesTotal)
+	}
+
+	// Frames saved again after resuming are not counted twice.
+	parser.Parse("Fra:2 Mem:88.94M (Peak 95.12M) | Time:00:02.21 | Mem:2.01M, Peak:2.01M | Scene, ViewLayer | Sample 128/128")
+	parser.Parse("Saved: '/work/output/0002_L.png'")
+	parser.Parse("Fra:3 Mem:88.94M (Peak 95.12M) | Time:00:02.21 | Mem:2.01M, Peak:2.01M | Scene, ViewLayer | Sample 128/128")
+	parser.Parse("Saved: '/work/output/0003_L.png'")
+
+	if progress := parser.Progress(); progress.FramesCompleted != 3 {
+		t.Errorf("FramesCompleted = %d, want 3", progress.FramesCompleted)
+	}
+}
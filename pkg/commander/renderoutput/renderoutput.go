@@ -0,0 +1,234 @@
+// Package renderoutput checks the frames a render wrote before they are pushed
+// to the artifact store.
+//
+// Every expected frame has to exist and be non-empty. PNG and JPEG frames have
+// to decode and must not be fully black or fully transparent, EXR frames have to
+// start with an OpenEXR header. Frames named after one of these formats have to
+// be in it. Files in other formats are only checked for size.
+package renderoutput
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/flowshot-io/commander/pkg/commander/frames"
+)
+
+const (
+	Missing     Problem = "missing"
+	Empty       Problem = "empty"
+	Invalid     Problem = "invalid"
+	Black       Problem = "black"
+	Transparent Problem = "transparent"
+
+	// maxListed bounds the frames named in an Error message.
+	maxListed = 20
+)
+
+var (
+	pngMagic  = []byte("\x89PNG\r\n\x1a\n")
+	jpegMagic = []byte{0xff, 0xd8, 0xff}
+	exrMagic  = []byte{0x76, 0x2f, 0x31, 0x01}
+
+	// extensionMagic is the header files with a known extension have to start with.
+	extensionMagic = map[string][]byte{
+		".png":  pngMagic,
+		".jpg":  jpegMagic,
+		".jpeg": jpegMagic,
+		".exr":  exrMagic,
+	}
+)
+
+type (
+	// Problem is what is wrong with a frame.
+	Problem string
+
+	Options struct {
+		// AllowBlank accepts fully black or transparent frames, such as the frames of a fade.
+		AllowBlank bool
+	}
+
+	// FrameProblem is a frame that failed validation.
+	FrameProblem struct {
+		Frame   int
+		Path    string
+		Problem Problem
+		Detail  string
+	}
+
+	// Error is returned by Validate and lists every frame that failed validation.
+	Error struct {
+		Frames []FrameProblem
+	}
+)
+
+// Validate checks the frames of frameSpec in outputDir. files maps frames to the
+// file the renderer reported saving them to. Frames not in files are looked up
+// in outputDir by the frame number at the end of the file name, such as 0042.png.
+func Validate(outputDir string, frameSpec frames.Spec, files map[int]string, opts Options) error {
+	found, err := frameFiles(outputDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var problems []FrameProblem
+	for _, frame := range frameSpec.Frames() {
+		path, ok := files[frame]
+		if !ok {
+			path, ok = found[frame]
+		}
+
+		if !ok {
+			problems = append(problems, FrameProblem{Frame: frame, Problem: Missing})
+			continue
+		}
+
+		if problem, detail := check(path, opts); problem != "" {
+			problems = append(problems, FrameProblem{Frame: frame, Path: path, Problem: problem, Detail: detail})
+		}
+	}
+
+	if len(problems) > 0 {
+		return &Error{Frames: problems}
+	}
+
+	return nil
+}
+
+func (e *Error) Error() string {
+	listed := make([]string, 0, maxListed)
+	for i, p := range e.Frames {
+		if i == maxListed {
+			listed = append(listed, fmt.Sprintf("and %d more", len(e.Frames)-maxListed))
+			break
+		}
+
+		s := fmt.Sprintf("frame %d %s", p.Frame, p.Problem)
+		if p.Detail != "" {
+			s += " (" + p.Detail + ")"
+		}
+
+		listed = append(listed, s)
+	}
+
+	return fmt.Sprintf("invalid render output: %s", strings.Join(listed, ", "))
+}
+
+// frameFiles maps the frame numbers at the end of the file names in dir to their paths.
+func frameFiles(dir string) (map[int]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[int]string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		digits := len(name) - len(strings.TrimRight(name, "0123456789"))
+		if digits == 0 {
+			continue
+		}
+
+		frame, err := strconv.Atoi(name[len(name)-digits:])
+		if err != nil {
+			continue
+		}
+
+		if _, ok := files[frame]; !ok {
+			files[frame] = filepath.Join(dir, entry.Name())
+		}
+	}
+
+	return files, nil
+}
+
+// check returns what is wrong with the frame at path, if anything.
+func check(path string, opts Options) (Problem, string) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return Missing, ""
+	}
+	if err != nil {
+		return Invalid, err.Error()
+	}
+	defer file.Close()
+
+	header := make([]byte, len(pngMagic))
+	n, err := io.ReadFull(file, header)
+	if n == 0 {
+		return Empty, ""
+	}
+	header = header[:n]
+
+	if magic, ok := extensionMagic[strings.ToLower(filepath.Ext(path))]; ok && !bytes.HasPrefix(header, magic) {
+		return Invalid, fmt.Sprintf("not a %s file", strings.ToUpper(strings.TrimPrefix(filepath.Ext(path), ".")))
+	}
+
+	switch {
+	case bytes.HasPrefix(header, exrMagic):
+		return "", ""
+	case bytes.HasPrefix(header, pngMagic), bytes.HasPrefix(header, jpegMagic):
+	default:
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return Invalid, err.Error()
+		}
+
+		// Other formats are not decoded.
+		return "", ""
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Invalid, err.Error()
+	}
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return Invalid, err.Error()
+	}
+
+	if opts.AllowBlank {
+		return "", ""
+	}
+
+	return blank(img), ""
+}
+
+// blank returns Transparent or Black if every pixel of img is, and an empty Problem otherwise.
+func blank(img image.Image) Problem {
+	transparent, black := true, true
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a != 0 {
+				transparent = false
+			}
+			if r != 0 || g != 0 || b != 0 {
+				black = false
+			}
+
+			if !transparent && !black {
+				return ""
+			}
+		}
+	}
+
+	// Colors are alpha premultiplied, so a transparent frame is also black.
+	if transparent {
+		return Transparent
+	}
+
+	return Black
+}
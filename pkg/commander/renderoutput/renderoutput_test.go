@@ -0,0 +1,180 @@
+package renderoutput
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flowshot-io/commander/pkg/commander/frames"
+)
+
+// frameImage returns a 4x4 image filled with c.
+func frameImage(c color.Color) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	return img
+}
+
+func encodePNG(t *testing.T, c color.Color) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, frameImage(c)); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, c color.Color) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, frameImage(c), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func writeFile(t *testing.T, dir string, name string, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestValidate(t *testing.T) {
+	gray := color.NRGBA{R: 128, G: 128, B: 128, A: 255}
+	black := color.NRGBA{A: 255}
+	transparent := color.NRGBA{}
+
+	tests := []struct {
+		name        string
+		file        string
+		data        func(t *testing.T) []byte
+		want        Problem
+		wantAllowed Problem
+	}{
+		{"png", "0001.png", func(t *testing.T) []byte { return encodePNG(t, gray) }, "", ""},
+		{"jpeg", "0001.jpg", func(t *testing.T) []byte { return encodeJPEG(t, gray) }, "", ""},
+		{"exr", "0001.exr", func(t *testing.T) []byte { return append(append([]byte(nil), exrMagic...), 0x02, 0, 0, 0) }, "", ""},
+		{"other format", "0001.tif", func(t *testing.T) []byte { return []byte("II*\x00") }, "", ""},
+		{"empty", "0001.png", func(t *testing.T) []byte { return nil }, Empty, Empty},
+		{"truncated png", "0001.png", func(t *testing.T) []byte { data := encodePNG(t, gray); return data[:len(data)/2] }, Invalid, Invalid},
+		{"truncated jpeg", "0001.jpg", func(t *testing.T) []byte { data := encodeJPEG(t, gray); return data[:len(data)/2] }, Invalid, Invalid},
+		{"png magic only", "0001.png", func(t *testing.T) []byte { return pngMagic }, Invalid, Invalid},
+		{"bad exr magic", "0001.exr", func(t *testing.T) []byte { return []byte("not an exr file") }, Invalid, Invalid},
+		{"short exr", "0001.exr", func(t *testing.T) []byte { return exrMagic[:2] }, Invalid, Invalid},
+		{"jpeg named png", "0001.png", func(t *testing.T) []byte { return encodeJPEG(t, gray) }, Invalid, Invalid},
+		{"black png", "0001.png", func(t *testing.T) []byte { return encodePNG(t, black) }, Black, ""},
+		{"black jpeg", "0001.jpg", func(t *testing.T) []byte { return encodeJPEG(t, black) }, Black, ""},
+		{"transparent png", "0001.png", func(t *testing.T) []byte { return encodePNG(t, transparent) }, Transparent, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeFile(t, dir, tt.file, tt.data(t))
+
+			for _, opts := range []Options{{}, {AllowBlank: true}} {
+				want := tt.want
+				if opts.AllowBlank {
+					want = tt.wantAllowed
+				}
+
+				err := Validate(dir, frames.Contiguous(1, 1), nil, opts)
+				if want == "" {
+					if err != nil {
+						t.Errorf("Validate(%+v) = %v, want nil", opts, err)
+					}
+					continue
+				}
+
+				var invalid *Error
+				if !errors.As(err, &invalid) || len(invalid.Frames) != 1 {
+					t.Fatalf("Validate(%+v) = %v, want one frame %s", opts, err, want)
+				}
+
+				if got := invalid.Frames[0]; got.Frame != 1 || got.Path != path || got.Problem != want {
+					t.Errorf("Validate(%+v) = %+v, want frame 1 %s at %s", opts, got, want, path)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMissing(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "render_0001.png", encodePNG(t, color.White))
+	writeFile(t, dir, "render_0003.png", encodePNG(t, color.White))
+
+	err := Validate(dir, frames.Contiguous(1, 4), map[int]string{3: filepath.Join(dir, "gone.png")}, Options{})
+
+	var invalid *Error
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Validate() = %v, want an *Error", err)
+	}
+
+	// Frame 3 was reported saved to a file that is gone, the file named after it is not used instead.
+	want := []FrameProblem{
+		{Frame: 2, Problem: Missing},
+		{Frame: 3, Path: filepath.Join(dir, "gone.png"), Problem: Missing},
+		{Frame: 4, Problem: Missing},
+	}
+	if !reflect.DeepEqual(invalid.Frames, want) {
+		t.Errorf("Validate() = %+v, want %+v", invalid.Frames, want)
+	}
+}
+
+func TestValidateMissingDir(t *testing.T) {
+	err := Validate(filepath.Join(t.TempDir(), "output"), frames.Contiguous(1, 2), nil, Options{AllowBlank: true})
+
+	var invalid *Error
+	if !errors.As(err, &invalid) || len(invalid.Frames) != 2 || invalid.Frames[0].Problem != Missing {
+		t.Errorf("Validate() = %v, want frames 1 and 2 missing", err)
+	}
+}
+
+func TestValidateReportedFiles(t *testing.T) {
+	// Frames are checked at the file the renderer reported, wherever it is.
+	dir := t.TempDir()
+	other := t.TempDir()
+	files := map[int]string{
+		1: writeFile(t, other, "first.png", encodePNG(t, color.White)),
+		2: writeFile(t, other, "second.exr", append(append([]byte(nil), exrMagic...), 0x02)),
+	}
+
+	if err := Validate(dir, frames.Contiguous(1, 2), files, Options{}); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+}
+
+func TestErrorListsFrames(t *testing.T) {
+	err := Validate(t.TempDir(), frames.Contiguous(1, maxListed+5), nil, Options{})
+	if err == nil {
+		t.Fatal("Validate() = nil, want an error")
+	}
+
+	msg := err.Error()
+	if !strings.HasPrefix(msg, "invalid render output: frame 1 missing, frame 2 missing") || !strings.HasSuffix(msg, "and 5 more") {
+		t.Errorf("Error() = %q", msg)
+	}
+}
@@ -0,0 +1,81 @@
+// Package replay replays recorded workflow histories against the current
+// workflow code to catch changes that would break running executions.
+package replay
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/flowshot-io/commander/pkg/commander/noderegistry"
+	"github.com/flowshot-io/commander/pkg/commander/services/blenderfarm"
+	"github.com/flowshot-io/commander/pkg/commander/services/blendernode"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Histories returns every JSON history file below dir, sorted by path.
+func Histories(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() && filepath.Ext(path) == ".json" {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// Replay replays a single history file against the registered workflows.
+// Workflows derive child workflow IDs from their own ID, so history files are
+// named after the workflow they belong to: "<workflow-id>.json", or
+// "<workflow-id>.<run>.json" for runs of a workflow that continued as new.
+func Replay(logger log.Logger, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	history, err := client.HistoryFromJSON(f, client.HistoryJSONOptions{})
+	if err != nil {
+		return fmt.Errorf("load %s: %w", file, err)
+	}
+
+	replayer := worker.NewWorkflowReplayer()
+	replayer.RegisterWorkflowWithOptions(blenderfarm.BlenderFarmWorkflow, workflow.RegisterOptions{Name: blenderfarm.WorkflowType})
+	replayer.RegisterWorkflow(blendernode.BlenderNodeWorkflow)
+	replayer.RegisterWorkflowWithOptions(noderegistry.RegistryWorkflow, workflow.RegisterOptions{Name: noderegistry.WorkflowType})
+
+	err = replayer.ReplayWorkflowHistoryWithOptions(logger, history, worker.ReplayWorkflowHistoryOptions{
+		OriginalExecution: workflow.Execution{ID: workflowID(file)},
+	})
+	if err != nil {
+		return fmt.Errorf("replay %s: %w", file, err)
+	}
+
+	return nil
+}
+
+func workflowID(file string) string {
+	name := filepath.Base(file)
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+
+	return name
+}
@@ -0,0 +1,47 @@
+package replay
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/flowshot-io/x/pkg/logger"
+	"github.com/flowshot-io/x/pkg/temporallogger"
+)
+
+// historiesDir is testdata/histories at the root of the repository.
+var historiesDir = filepath.Join("..", "..", "..", "testdata", "histories")
+
+func TestReplayHistories(t *testing.T) {
+	files, err := Histories(historiesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) == 0 {
+		t.Fatalf("no histories found in %s", historiesDir)
+	}
+
+	logger := temporallogger.New(logger.NoOp())
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(filepath.Dir(file))+"/"+filepath.Base(file), func(t *testing.T) {
+			if err := Replay(logger, file); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestWorkflowID(t *testing.T) {
+	tests := map[string]string{
+		"testdata/histories/blenderfarm/complete.json":             "complete",
+		"testdata/histories/blenderfarm/continue-as-new.run2.json": "continue-as-new",
+		"testdata/histories/blendernode/workspace-manager.json":    "workspace-manager",
+	}
+
+	for file, want := range tests {
+		if got := workflowID(file); got != want {
+			t.Errorf("workflowID(%q) = %q, want %q", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package blenderfarm
+
+import (
+	"time"
+
+	"github.com/flowshot-io/commander/pkg/commander/frames"
+	"github.com/flowshot-io/commander/pkg/commander/services/blendernode"
+)
+
+const (
+	BatchPending  BatchStatus = "pending"
+	BatchRunning  BatchStatus = "running"
+	BatchDone     BatchStatus = "done"
+	BatchFailed   BatchStatus = "failed"
+	BatchCanceled BatchStatus = "canceled"
+)
+
+type (
+	// BatchStatus is the state of a single render batch.
+	BatchStatus string
+
+	// Batch tracks the frames rendered by one BlenderNodeWorkflow.
+	Batch struct {
+		Index       int
+		WorkflowID  string
+		Frames      frames.Spec
+		Status      BatchStatus
+		Artifact    string
+		Node        string
+		Error       string
+		Attempts    int
+		StartedAt   time.Time
+		CompletedAt time.Time
+	}
+
+	// Progress is the state of every batch of a job.
+	Progress struct {
+		Artifact string
+		Batches  []Batch
+		Failed   bool
+		Canceled bool
+		// CancelReason is the reason sent with CancelSignal when the job was canceled.
+		CancelReason string
+		// Job is the workflow ID of the job when the artifacts of its batches are named after it.
+		Job string
+	}
+
+	// Summary is the result of the Query query handler. Its size does not depend on the number
+	// of batches of the job, the batches themselves are listed by the BatchesQuery query handler.
+	Summary struct {
+		Artifact        string
+		Status          BatchStatus
+		FramesTotal     int
+		FramesDone      int
+		BatchesTotal    int
+		BatchesRunning  int
+		BatchesDone     int
+		BatchesFailed   int
+		BatchesCanceled int
+		// CancelReason is the reason sent with CancelSignal when the job was canceled.
+		CancelReason string
+		// Job is set when the artifacts of the batches are named after the job, see blendernode.OutputArtifactName.
+		Job string
+	}
+
+	// BatchPage is the result of the BatchesQuery query handler.
+	BatchPage struct {
+		Batches []Batch
+		// Total is the number of batches with the queried status.
+		Total int
+	}
+)
+
+// newProgress returns the progress of a job whose frames were split into batches, all of them pending.
+func newProgress(artifact string, batches []frames.Spec) Progress {
+	progress := Progress{Artifact: artifact}
+	for i, batchFrames := range batches {
+		progress.Batches = append(progress.Batches, Batch{
+			Index:  i,
+			Frames: batchFrames,
+			Status: BatchPending,
+		})
+	}
+
+	return progress
+}
+
+// Result returns the outcome of the batch.
+func (b Batch) Result() BatchResult {
+	result := BatchResult{
+		Frames:   b.Frames,
+		Artifact: b.Artifact,
+		Error:    b.Error,
+		Attempts: b.Attempts,
+	}
+
+	if !b.StartedAt.IsZero() && !b.CompletedAt.IsZero() {
+		result.Duration = b.CompletedAt.Sub(b.StartedAt)
+	}
+
+	return result
+}
+
+// Results returns the outcome of every batch in frame order.
+func (p Progress) Results() []BatchResult {
+	results := make([]BatchResult, 0, len(p.Batches))
+	for _, b := range p.Batches {
+		results = append(results, b.Result())
+	}
+
+	return results
+}
+
+// FramesTotal returns the number of frames in the job.
+func (p Progress) FramesTotal() int {
+	total := 0
+	for _, b := range p.Batches {
+		total += b.Frames.Len()
+	}
+
+	return total
+}
+
+// FramesDone returns the number of frames in batches that have completed.
+func (p Progress) FramesDone() int {
+	done := 0
+	for _, b := range p.Batches {
+		if b.Status == BatchDone {
+			done += b.Frames.Len()
+		}
+	}
+
+	return done
+}
+
+// Summary returns the counts of frames and batches of the job.
+func (p Progress) Summary() Summary {
+	summary := Summary{
+		Artifact:     p.Artifact,
+		Job:          p.Job,
+		Status:       p.Status(),
+		FramesTotal:  p.FramesTotal(),
+		FramesDone:   p.FramesDone(),
+		BatchesTotal: len(p.Batches),
+		CancelReason: p.CancelReason,
+	}
+
+	for _, b := range p.Batches {
+		switch b.Status {
+		case BatchRunning:
+			summary.BatchesRunning++
+		case BatchDone:
+			summary.BatchesDone++
+		case BatchFailed:
+			summary.BatchesFailed++
+		case BatchCanceled:
+			summary.BatchesCanceled++
+		}
+	}
+
+	return summary
+}
+
+// Page returns up to limit batches with the given status, all batches when status is empty,
+// skipping the first offset of them. The limit is capped at MaxBatchPageSize.
+func (p Progress) Page(status BatchStatus, offset int, limit int) BatchPage {
+	if limit <= 0 || limit > MaxBatchPageSize {
+		limit = MaxBatchPageSize
+	}
+
+	var page BatchPage
+	for _, b := range p.Batches {
+		if status != "" && b.Status != status {
+			continue
+		}
+
+		if page.Total >= offset && len(page.Batches) < limit {
+			page.Batches = append(page.Batches, b)
+		}
+		page.Total++
+	}
+
+	return page
+}
+
+// PercentComplete returns the share of rendered frames between 0 and 100.
+func (s Summary) PercentComplete() float64 {
+	if s.FramesTotal == 0 {
+		return 0
+	}
+
+	return float64(s.FramesDone) / float64(s.FramesTotal) * 100
+}
+
+// OutputArtifacts returns the output artifacts of completed batches in frame order.
+func (p Progress) OutputArtifacts() []string {
+	var artifacts []string
+	for _, b := range p.Batches {
+		if b.Status == BatchDone && b.Artifact != "" {
+			artifacts = append(artifacts, b.Artifact)
+		}
+	}
+
+	return artifacts
+}
+
+// LogArtifacts returns the render log artifacts of batches that finished in frame order. Canceled
+// batches stop before they push their render log.
+func (p Progress) LogArtifacts() []string {
+	var artifacts []string
+	for _, b := range p.Batches {
+		if b.Status == BatchDone || b.Status == BatchFailed {
+			artifacts = append(artifacts, blendernode.LogArtifactName(p.Job, p.Artifact, b.Frames))
+		}
+	}
+
+	return artifacts
+}
+
+// Status summarises the state of the job. Failed batches only fail the job
+// once the failure policy has been exceeded.
+func (p Progress) Status() BatchStatus {
+	if p.Canceled {
+		return BatchCanceled
+	}
+
+	if p.Failed {
+		return BatchFailed
+	}
+
+	pending := 0
+	for _, b := range p.Batches {
+		switch b.Status {
+		case BatchRunning:
+			return BatchRunning
+		case BatchPending:
+			pending++
+		}
+	}
+
+	if len(p.Batches) > 0 && pending == len(p.Batches) {
+		return BatchPending
+	}
+	if pending > 0 {
+		return BatchRunning
+	}
+
+	return BatchDone
+}
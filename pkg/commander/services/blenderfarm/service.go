@@ -3,10 +3,13 @@ package blenderfarm
 import (
 	"fmt"
 
+	commanderactivities "github.com/flowshot-io/commander/pkg/commander/temporalactivities"
+	"github.com/flowshot-io/x/pkg/artifactservice"
 	"github.com/flowshot-io/x/pkg/logger"
 	"github.com/flowshot-io/x/pkg/manager"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
 )
 
 const Queue = "blenderfarm-queue"
@@ -14,6 +17,7 @@ const Queue = "blenderfarm-queue"
 type (
 	Options struct {
 		TemporalClient client.Client
+		ArtifactClient artifactservice.ArtifactServiceClient
 		Logger         logger.Logger
 	}
 
@@ -32,9 +36,15 @@ func New(opts Options) (manager.Service, error) {
 		return nil, fmt.Errorf("temporal client is required")
 	}
 
+	if opts.ArtifactClient == nil {
+		return nil, fmt.Errorf("artifact client is required")
+	}
+
 	worker := worker.New(opts.TemporalClient, Queue, worker.Options{})
 
-	worker.RegisterWorkflow(BlenderFarmWorkflow)
+	worker.RegisterWorkflowWithOptions(BlenderFarmWorkflow, workflow.RegisterOptions{Name: WorkflowType})
+	worker.RegisterActivity(commanderactivities.NewCleanupActivities(opts.ArtifactClient))
+	worker.RegisterActivity(commanderactivities.NewRoutingActivities(opts.TemporalClient))
 
 	return &Service{
 		worker: worker,
@@ -0,0 +1,194 @@
+package blenderfarm
+
+import (
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxCarriedErrors and maxCarriedErrorLength bound the errors of failed batches carried across ContinueAsNew.
+	maxCarriedErrors      = 100
+	maxCarriedErrorLength = 512
+
+	// earlierRunError is the error of a failed batch whose error was not carried across ContinueAsNew.
+	earlierRunError = "failed in an earlier run"
+)
+
+type (
+	// IndexSet is a set of batch indexes stored as sorted, inclusive ranges.
+	IndexSet [][2]int
+
+	// State is the state of a job carried across ContinueAsNew. Every run splits the frames of the
+	// job into the same batches again, so only the outcome of the batches that finished is carried,
+	// keeping the input of the next run small for jobs with thousands of batches.
+	State struct {
+		Done   IndexSet
+		Failed IndexSet
+		// Artifacts are the output artifacts of the done batches, keyed by the frames of the batch.
+		Artifacts map[string]string
+		// Errors are the errors of the first failed batches, keyed by the frames of the batch. The
+		// errors of later failed batches are not carried and read as earlierRunError.
+		Errors map[string]string
+		// Finished are the attempts, node and times of the batches in Done and Failed.
+		Finished FinishedBatches
+		// SteppedBatches is set when the batches keep the step of the frames of the job.
+		SteppedBatches bool
+		// Job is set when the artifacts of the batches are named after the job.
+		Job string
+	}
+)
+
+// FinishedBatches stores the attempts, node and times of finished batches as one column per field,
+// each in order of batch index, so that every batch adds a few numbers rather than a Batch.
+type FinishedBatches struct {
+	Attempts []int
+	// Nodes index NodeNames plus one, zero is a batch that did not report its node.
+	Nodes     []int
+	NodeNames []string
+	// StartedAt are in milliseconds since the Unix epoch and Durations in milliseconds, zero when unset.
+	StartedAt []int64
+	Durations []int64
+}
+
+// State returns the state of the batches that finished, to be carried across ContinueAsNew.
+func (p Progress) State() *State {
+	state := &State{
+		Job:       p.Job,
+		Artifacts: map[string]string{},
+		Errors:    map[string]string{},
+	}
+
+	nodes := map[string]int{}
+	for _, b := range p.Batches {
+		switch b.Status {
+		case BatchDone:
+			state.Done = state.Done.append(b.Index)
+			if b.Artifact != "" {
+				state.Artifacts[b.Frames.String()] = b.Artifact
+			}
+		case BatchFailed:
+			state.Failed = state.Failed.append(b.Index)
+			if len(state.Errors) < maxCarriedErrors {
+				state.Errors[b.Frames.String()] = truncate(b.Error, maxCarriedErrorLength)
+			}
+		default:
+			continue
+		}
+
+		state.Finished.append(b, nodes)
+	}
+
+	return state
+}
+
+// restore marks the batches that finished in earlier runs the way state records them.
+func (p *Progress) restore(state State) {
+	p.Job = state.Job
+
+	finished := 0
+	for i := range p.Batches {
+		b := &p.Batches[i]
+
+		switch {
+		case state.Done.Contains(b.Index):
+			b.Status = BatchDone
+			b.Artifact = state.Artifacts[b.Frames.String()]
+		case state.Failed.Contains(b.Index):
+			b.Status = BatchFailed
+			b.Error = state.Errors[b.Frames.String()]
+			if b.Error == "" {
+				b.Error = earlierRunError
+			}
+		default:
+			continue
+		}
+
+		// States carried before Finished was added leave it empty.
+		state.Finished.restore(b, finished)
+		finished++
+	}
+}
+
+// append adds the attempts, node and times of b, nodes maps the node names already added to their index.
+func (f *FinishedBatches) append(b Batch, nodes map[string]int) {
+	node := 0
+	if b.Node != "" {
+		if _, ok := nodes[b.Node]; !ok {
+			f.NodeNames = append(f.NodeNames, b.Node)
+			nodes[b.Node] = len(f.NodeNames)
+		}
+		node = nodes[b.Node]
+	}
+
+	var startedAt, duration int64
+	if !b.StartedAt.IsZero() {
+		startedAt = b.StartedAt.UnixMilli()
+		if !b.CompletedAt.IsZero() {
+			duration = b.CompletedAt.Sub(b.StartedAt).Milliseconds()
+		}
+	}
+
+	f.Attempts = append(f.Attempts, b.Attempts)
+	f.Nodes = append(f.Nodes, node)
+	f.StartedAt = append(f.StartedAt, startedAt)
+	f.Durations = append(f.Durations, duration)
+}
+
+// restore sets the attempts, node and times of b from the finished batch at index i, if there is one.
+func (f FinishedBatches) restore(b *Batch, i int) {
+	if i >= len(f.Attempts) || i >= len(f.Nodes) || i >= len(f.StartedAt) || i >= len(f.Durations) {
+		return
+	}
+
+	b.Attempts = f.Attempts[i]
+	if node := f.Nodes[i]; node > 0 && node <= len(f.NodeNames) {
+		b.Node = f.NodeNames[node-1]
+	}
+
+	if f.StartedAt[i] != 0 {
+		b.StartedAt = time.UnixMilli(f.StartedAt[i]).UTC()
+		if f.Durations[i] != 0 {
+			b.CompletedAt = b.StartedAt.Add(time.Duration(f.Durations[i]) * time.Millisecond)
+		}
+	}
+}
+
+// Contains reports whether index is in the set.
+func (s IndexSet) Contains(index int) bool {
+	i := sort.Search(len(s), func(i int) bool { return s[i][1] >= index })
+	return i < len(s) && s[i][0] <= index
+}
+
+// Len returns the number of indexes in the set.
+func (s IndexSet) Len() int {
+	n := 0
+	for _, r := range s {
+		n += r[1] - r[0] + 1
+	}
+
+	return n
+}
+
+// append adds index to the set, it must be greater than every index already in the set.
+func (s IndexSet) append(index int) IndexSet {
+	if n := len(s); n > 0 && s[n-1][1]+1 == index {
+		s[n-1][1] = index
+		return s
+	}
+
+	return append(s, [2]int{index, index})
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+
+	// Cut at the start of a rune so the error stays valid UTF-8.
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+
+	return s[:max]
+}
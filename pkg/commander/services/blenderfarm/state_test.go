@@ -0,0 +1,89 @@
+package blenderfarm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flowshot-io/commander/pkg/commander/frames"
+	"go.temporal.io/sdk/converter"
+)
+
+func TestStateRestore(t *testing.T) {
+	started := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	progress := newProgress("project", frames.Contiguous(1, 12).Batches(2))
+	progress.Job = "job"
+	progress.Batches[0] = Batch{Index: 0, Frames: frames.Contiguous(1, 2), Status: BatchDone, Artifact: "project-job-1-2", Node: "node-a", Attempts: 1, StartedAt: started, CompletedAt: started.Add(90 * time.Second)}
+	progress.Batches[1] = Batch{Index: 1, Frames: frames.Contiguous(3, 4), Status: BatchFailed, Error: "project is corrupt", Node: "node-b", Attempts: 3, StartedAt: started, CompletedAt: started.Add(1500 * time.Millisecond)}
+	progress.Batches[2] = Batch{Index: 2, Frames: frames.Contiguous(5, 6), Status: BatchRunning, Node: "node-a", Attempts: 1, StartedAt: started}
+	progress.Batches[4] = Batch{Index: 4, Frames: frames.Contiguous(9, 10), Status: BatchDone, Artifact: "project-job-9-10", Node: "node-a", Attempts: 2, StartedAt: started, CompletedAt: started.Add(time.Minute)}
+
+	// The state goes through the data converter the way it is carried across ContinueAsNew.
+	payloads, err := converter.GetDefaultDataConverter().ToPayloads(progress.State())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var state State
+	if err := converter.GetDefaultDataConverter().FromPayloads(payloads, &state); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"node-a", "node-b"}; !reflect.DeepEqual(state.Finished.NodeNames, want) {
+		t.Errorf("Finished.NodeNames = %v, want %v", state.Finished.NodeNames, want)
+	}
+
+	restored := newProgress("project", frames.Contiguous(1, 12).Batches(2))
+	restored.restore(state)
+
+	want := newProgress("project", frames.Contiguous(1, 12).Batches(2))
+	want.Job = "job"
+	want.Batches[0] = progress.Batches[0]
+	want.Batches[1] = progress.Batches[1]
+	want.Batches[4] = progress.Batches[4]
+
+	if !reflect.DeepEqual(restored, want) {
+		t.Errorf("restore(State()) = %+v, want %+v", restored, want)
+	}
+}
+
+func TestStateRestoreWithoutFinished(t *testing.T) {
+	// States carried before the attempts, node and times of finished batches were kept only restore their outcome.
+	restored := newProgress("project", frames.Contiguous(1, 4).Batches(2))
+	restored.restore(State{Done: IndexSet{{0, 0}}, Failed: IndexSet{{1, 1}}, Artifacts: map[string]string{"1-2": "project-1-2"}})
+
+	want := []Batch{
+		{Index: 0, Frames: frames.Contiguous(1, 2), Status: BatchDone, Artifact: "project-1-2"},
+		{Index: 1, Frames: frames.Contiguous(3, 4), Status: BatchFailed, Error: earlierRunError},
+	}
+
+	if !reflect.DeepEqual(restored.Batches, want) {
+		t.Errorf("restore() = %+v, want %+v", restored.Batches, want)
+	}
+}
+
+func TestStateCapsErrors(t *testing.T) {
+	progress := newProgress("project", frames.Contiguous(1, maxCarriedErrors+10).Batches(1))
+	for i := range progress.Batches {
+		progress.Batches[i].Status = BatchFailed
+		progress.Batches[i].Error = strings.Repeat("é", maxCarriedErrorLength)
+	}
+
+	state := progress.State()
+	if len(state.Errors) != maxCarriedErrors {
+		t.Errorf("len(Errors) = %d, want %d", len(state.Errors), maxCarriedErrors)
+	}
+
+	restored := newProgress("project", frames.Contiguous(1, maxCarriedErrors+10).Batches(1))
+	restored.restore(*state)
+
+	if got := restored.Batches[0].Error; len(got) != maxCarriedErrorLength {
+		t.Errorf("len(Error) = %d, want %d", len(got), maxCarriedErrorLength)
+	}
+
+	if got := restored.Batches[maxCarriedErrors].Error; got != earlierRunError {
+		t.Errorf("Error = %q, want %q", got, earlierRunError)
+	}
+}
@@ -0,0 +1,35 @@
+package blenderfarm
+
+// Change IDs of BlenderFarmWorkflow passed to workflow.GetVersion. A job runs for as long as its
+// batches render and continues as new along the way, so deploys routinely land while a run is
+// waiting on its children. Runs recorded before a change replay with workflow.DefaultVersion and
+// keep the old code path; every change gets a history under testdata/histories/blenderfarm that
+// the replay package tests against.
+const (
+	// failurePolicyChange applies FailurePolicy instead of always completing the job.
+	failurePolicyChange = "failure-policy"
+	// parallelBatchesChange limits the child workflows in flight to MaxParallelBatches.
+	parallelBatchesChange = "max-parallel-batches"
+	// batchWorkflowIDChange starts child workflows with the ID from BatchWorkflowID.
+	batchWorkflowIDChange = "batch-workflow-id"
+	// continueAsNewChange continues the job as new once the history grows past HistoryThreshold.
+	continueAsNewChange = "continue-as-new"
+	// cancelCleanupChange deletes the artifacts of completed batches when the job is canceled.
+	cancelCleanupChange = "cancel-cleanup"
+	// cacheRoutingChange prefers nodes that have the artifact cached when starting batches.
+	cacheRoutingChange = "cache-routing"
+	// cancelLogCleanupChange also deletes the render logs of finished batches when the job is canceled.
+	cancelLogCleanupChange = "cancel-log-cleanup"
+	// failFastCancelChange waits for the batches a failed job cancels before the job closes.
+	failFastCancelChange = "fail-fast-cancel"
+	// noFramesChange fails jobs that split into no batches with ErrNoFrames instead of completing them.
+	noFramesChange = "no-frames"
+	// compactStateChange carries State instead of the Progress of every batch across ContinueAsNew.
+	compactStateChange = "compact-state"
+	// frameLimitsChange fails jobs whose frames exceed MaxFrames or MaxBatches.
+	frameLimitsChange = "frame-limits"
+	// steppedBatchesChange keeps the step of stepped frame ranges in batches instead of listing their frames.
+	steppedBatchesChange = "stepped-batches"
+	// jobArtifactsChange names the artifacts of the batches after the job as well as the artifact and frames.
+	jobArtifactsChange = "job-artifacts"
+)
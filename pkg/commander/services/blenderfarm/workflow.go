@@ -1,29 +1,143 @@
 package blenderfarm
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/flowshot-io/commander/pkg/commander/frames"
 	"github.com/flowshot-io/commander/pkg/commander/services/blendernode"
+	commanderactivities "github.com/flowshot-io/commander/pkg/commander/temporalactivities"
+	enumspb "go.temporal.io/api/enums/v1"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
-const Query = "blenderfarm-query"
+const (
+	// Query returns the Summary of the job.
+	Query = "blenderfarm-query"
+	// BatchesQuery returns a BatchPage of the batches of the job. It takes the BatchStatus of the
+	// batches to list, or an empty status for every batch, followed by an offset and a limit.
+	BatchesQuery = "blenderfarm-batches"
+	WorkflowType = "BlenderFarmWorkflow"
+	// CancelSignal carries the reason for a cancellation and is sent before the cancellation is requested.
+	CancelSignal = "blenderfarm-cancel"
+
+	// ErrBatchesFailed is the application error type returned when the failure policy is exceeded.
+	ErrBatchesFailed = "BatchesFailed"
+	// ErrNoFrames is the application error type returned when a job has no frames to render.
+	ErrNoFrames = "NoFrames"
+	// ErrTooManyFrames is the application error type returned when a job exceeds MaxFrames or MaxBatches.
+	ErrTooManyFrames = "TooManyFrames"
+
+	// MaxFrames and MaxBatches bound the frames and batches of a job, keeping the state and the
+	// results of the job well below the payload size limit of Temporal.
+	MaxFrames  = 1000000
+	MaxBatches = 10000
+
+	// MaxBatchPageSize is the largest number of batches returned by BatchesQuery.
+	MaxBatchPageSize = 500
+
+	// DefaultMaxBatchAttempts is used when a job does not set MaxBatchAttempts.
+	DefaultMaxBatchAttempts = 3
+
+	// DefaultMaxParallelBatches is used when a job does not set MaxParallelBatches.
+	DefaultMaxParallelBatches = 10
+
+	// DefaultHistoryThreshold is used when a job does not set HistoryThreshold.
+	DefaultHistoryThreshold = 10000
+
+	// DefaultCacheRoutingTimeout is used when a job does not set CacheRoutingTimeout.
+	DefaultCacheRoutingTimeout = 2 * time.Minute
+)
+
+const (
+	// FailFast fails the job as soon as a single batch fails.
+	FailFast FailurePolicy = "fail-fast"
+	// Tolerate fails the job once more than MaxFailedBatches batches have failed.
+	Tolerate FailurePolicy = "tolerate"
+	// Complete always completes the job, returning the results of the batches that succeeded.
+	Complete FailurePolicy = "complete"
+)
 
 type (
+	// FailurePolicy decides whether failed batches fail the whole job.
+	FailurePolicy string
+
 	BlenderFarmWorkflowInput struct {
-		Artifact   string
-		StartFrame int
-		EndFrame   int
-		BatchSize  int
+		Artifact string
+		// Frames lists the frames to render. When empty, StartFrame to EndFrame is rendered.
+		Frames             frames.Spec
+		StartFrame         int
+		EndFrame           int
+		BatchSize          int
+		FailurePolicy      FailurePolicy
+		MaxFailedBatches   int
+		MaxParallelBatches int
+		// HistoryThreshold is the history length after which the job continues as new.
+		HistoryThreshold int
+		// CacheRoutingTimeout is how long a batch waits for a node that has the artifact cached
+		// before it is rendered by any node.
+		CacheRoutingTimeout time.Duration
+		// MaxBatchAttempts caps the runs of a batch and MaxActivityAttempts the attempts of each activity.
+		// Failures in commanderactivities.PermanentErrors are never retried.
+		MaxBatchAttempts    int
+		MaxActivityAttempts int
+		// Renderer overrides the default renderer of the nodes when set.
+		Renderer string
+		// State carries the outcome of finished batches across ContinueAsNew and is left nil by callers.
+		State *State
+		// Progress carried the state of every batch across ContinueAsNew before State replaced it.
+		// It is only set by runs that continued before then.
+		Progress *Progress
 	}
 
 	BlenderFarmWorkflowOutput struct {
-		Result []string
+		Results []BatchResult
+		// CancelReason is only set on the details of the canceled error returned when the job is canceled.
+		CancelReason string
+	}
+
+	// BatchResult is the outcome of a single batch.
+	BatchResult struct {
+		Frames   frames.Spec
+		Artifact string
+		// Error is cut to 512 bytes for batches that failed before the job continued as new, and only the
+		// errors of the first 100 of them are kept, the others read "failed in an earlier run".
+		Error    string
+		Attempts int
+		Duration time.Duration
 	}
 )
 
 func BlenderFarmWorkflow(ctx workflow.Context, request BlenderFarmWorkflowInput) (BlenderFarmWorkflowOutput, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Render started", "Artifact", request.Artifact, "Frames", request.Frames.String(), "StartFrame", request.StartFrame, "EndFrame", request.EndFrame, "BatchSize", request.BatchSize, "FailurePolicy", request.FailurePolicy, "MaxParallelBatches", request.MaxParallelBatches)
+
+	if request.FailurePolicy == "" {
+		request.FailurePolicy = FailFast
+	}
+
+	if request.MaxParallelBatches <= 0 {
+		request.MaxParallelBatches = DefaultMaxParallelBatches
+	}
+
+	if request.HistoryThreshold <= 0 {
+		request.HistoryThreshold = DefaultHistoryThreshold
+	}
+
+	if request.CacheRoutingTimeout <= 0 {
+		request.CacheRoutingTimeout = DefaultCacheRoutingTimeout
+	}
+
+	if request.MaxBatchAttempts <= 0 {
+		request.MaxBatchAttempts = DefaultMaxBatchAttempts
+	}
+
+	if request.MaxActivityAttempts <= 0 {
+		request.MaxActivityAttempts = blendernode.DefaultMaxActivityAttempts
+	}
+
 	ao := workflow.ActivityOptions{
 		StartToCloseTimeout: 60 * time.Minute,
 		HeartbeatTimeout:    1 * time.Minute,
@@ -31,77 +145,378 @@ func BlenderFarmWorkflow(ctx workflow.Context, request BlenderFarmWorkflowInput)
 			InitialInterval:    time.Second,
 			BackoffCoefficient: 2.0,
 			MaximumInterval:    time.Minute,
+			MaximumAttempts:    int32(request.MaxActivityAttempts),
 		},
 	}
 
 	cwo := workflow.ChildWorkflowOptions{
 		TaskQueue: blendernode.Queue,
+		// A batch can only be started again under the same ID once its previous run failed.
+		WorkflowIDReusePolicy: enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE_FAILED_ONLY,
 		RetryPolicy: &temporal.RetryPolicy{
-			InitialInterval: 10 * time.Second,
+			InitialInterval:        10 * time.Second,
+			MaximumAttempts:        int32(request.MaxBatchAttempts),
+			NonRetryableErrorTypes: commanderactivities.PermanentErrors,
 		},
 	}
 
 	ctx = workflow.WithChildOptions(ctx, cwo)
 	ctx = workflow.WithActivityOptions(ctx, ao)
 
-	logger := workflow.GetLogger(ctx)
-	logger.Info("Render started", "Artifact", request.Artifact, "StartFrame", request.StartFrame, "EndFrame", request.EndFrame, "BatchSize", request.BatchSize)
+	if len(request.Frames) == 0 {
+		request.Frames = frames.Contiguous(request.StartFrame, request.EndFrame)
+	}
+
+	// Split the frames into batches of BatchSize frames and restore the batches that finished in
+	// earlier runs, unless an earlier run carried the state of every batch.
+	var progress Progress
+	var steppedBatches bool
+	if request.Progress != nil {
+		progress = *request.Progress
+	} else {
+		if err := ValidateFrames(request.Frames, request.BatchSize); err != nil &&
+			workflow.GetVersion(ctx, frameLimitsChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion {
+			return BlenderFarmWorkflowOutput{}, temporal.NewNonRetryableApplicationError(err.Error(), ErrTooManyFrames, nil)
+		}
 
-	if (request.BatchSize <= 0) || (request.BatchSize > (request.EndFrame - request.StartFrame + 1)) {
-		request.BatchSize = request.EndFrame - request.StartFrame + 1
+		// Runs continuing a job split its frames the way its first run did, so the state they carry
+		// keeps matching the batches.
+		if request.State != nil {
+			steppedBatches = request.State.SteppedBatches
+		} else {
+			steppedBatches = workflow.GetVersion(ctx, steppedBatchesChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion
+		}
+
+		batches := request.Frames.Batches(request.BatchSize)
+		if !steppedBatches {
+			for i := range batches {
+				batches[i] = batches[i].Runs()
+			}
+		}
+
+		progress = newProgress(request.Artifact, batches)
+		if request.State != nil {
+			progress.restore(*request.State)
+		} else if workflow.GetVersion(ctx, jobArtifactsChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion {
+			progress.Job = workflow.GetInfo(ctx).WorkflowExecution.ID
+		}
+
+		// Executions started before jobs without frames were rejected complete with no results.
+		if len(progress.Batches) == 0 && workflow.GetVersion(ctx, noFramesChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion {
+			return BlenderFarmWorkflowOutput{}, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("frames %q contain no frames to render", request.Frames.String()),
+				ErrNoFrames,
+				nil,
+			)
+		}
+	}
+
+	// Executions started before these changes keep the behaviour they started with.
+	if workflow.GetVersion(ctx, failurePolicyChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		request.FailurePolicy = Complete
+	}
+
+	if workflow.GetVersion(ctx, parallelBatchesChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		request.MaxParallelBatches = len(progress.Batches)
+	}
+
+	batchWorkflowIDs := workflow.GetVersion(ctx, batchWorkflowIDChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion
+	canContinueAsNew := workflow.GetVersion(ctx, continueAsNewChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion
+
+	// Batches are spread over the nodes that already have the artifact cached.
+	var preferredQueues []string
+	if workflow.GetVersion(ctx, cacheRoutingChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion {
+		preferredQueues = locateArtifact(ctx, request.Artifact)
 	}
 
-	// Calculate the number of batches required based on the batch size and frame range.
-	numBatches := (request.EndFrame - request.StartFrame + 1) / request.BatchSize
-	if (request.EndFrame-request.StartFrame+1)%request.BatchSize != 0 {
-		numBatches++
+	err := workflow.SetQueryHandler(ctx, Query, func() (Summary, error) {
+		return progress.Summary(), nil
+	})
+	if err != nil {
+		return BlenderFarmWorkflowOutput{}, err
 	}
 
-	// Create a channel to receive the output of child workflows.
-	resultCh := workflow.NewChannel(ctx)
+	err = workflow.SetQueryHandler(ctx, BatchesQuery, func(status BatchStatus, offset int, limit int) (BatchPage, error) {
+		return progress.Page(status, offset, limit), nil
+	})
+	if err != nil {
+		return BlenderFarmWorkflowOutput{}, err
+	}
+
+	childCtx, cancelChildren := workflow.WithCancel(ctx)
+	defer cancelChildren()
 
-	// Start child workflows for each batch.
-	for i := 0; i < numBatches; i++ {
-		startFrame := request.StartFrame + i*request.BatchSize
-		endFrame := request.StartFrame + (i+1)*request.BatchSize - 1
-		if endFrame > request.EndFrame {
-			endFrame = request.EndFrame
+	// Create a channel to receive the index of each finished batch.
+	doneCh := workflow.NewChannel(ctx)
+
+	// startBatch starts the child workflow for a batch and reports its index on doneCh once it finishes.
+	startBatch := func(batch *Batch) {
+		childCtx := childCtx
+		if batchWorkflowIDs {
+			batch.WorkflowID = BatchWorkflowID(workflow.GetInfo(ctx).WorkflowExecution.ID, batch.Index, batch.Frames)
+			childCtx = workflow.WithWorkflowID(childCtx, batch.WorkflowID)
 		}
 
-		childWorkflow := workflow.ExecuteChildWorkflow(ctx, blendernode.BlenderNodeWorkflow, blendernode.BlenderNodeWorkflowInput{
-			Artifact:   request.Artifact,
-			StartFrame: startFrame,
-			EndFrame:   endFrame,
-		})
+		input := blendernode.BlenderNodeWorkflowInput{
+			Artifact: request.Artifact,
+			Frames:   batch.Frames,
+			Job:      progress.Job,
+			Renderer: request.Renderer,
+			// The batch retries itself, so its activities share the attempts of the job.
+			MaxActivityAttempts: request.MaxActivityAttempts,
+		}
+		if len(preferredQueues) > 0 {
+			input.PreferredQueue = preferredQueues[batch.Index%len(preferredQueues)]
+			input.PreferredQueueTimeout = request.CacheRoutingTimeout
+		}
+
+		childWorkflow := workflow.ExecuteChildWorkflow(childCtx, blendernode.BlenderNodeWorkflow, input)
+
+		workflow.Go(childCtx, func(ctx workflow.Context) {
+			if err := childWorkflow.GetChildWorkflowExecution().Get(ctx, nil); err == nil {
+				batch.Status = BatchRunning
+				batch.StartedAt = workflow.Now(ctx)
+			}
 
-		// Send the workflow execution result to the channel.
-		workflow.Go(ctx, func(ctx workflow.Context) {
 			var childWorkflowOutput blendernode.BlenderNodeWorkflowOutput
 			err := childWorkflow.Get(ctx, &childWorkflowOutput)
-			if err != nil {
-				resultCh.Send(ctx, blendernode.BlenderNodeWorkflowOutput{Result: "ERR"})
-				return
+			batch.CompletedAt = workflow.Now(ctx)
+			if temporal.IsCanceledError(err) {
+				batch.Status = BatchCanceled
+				batch.Error = err.Error()
+			} else if err != nil {
+				batch.Status = BatchFailed
+				batch.Error = err.Error()
+				batch.Attempts = batchAttempts(err)
+			} else {
+				batch.Status = BatchDone
+				batch.Artifact = childWorkflowOutput.Result
+				batch.Node = childWorkflowOutput.Node
+				batch.Attempts = childWorkflowOutput.Attempt
+			}
+
+			doneCh.Send(ctx, batch.Index)
+		})
+	}
+
+	// Batches carried over from a previous run keep their state, only pending batches are started.
+	var pending []*Batch
+	failed := 0
+	for i := range progress.Batches {
+		switch progress.Batches[i].Status {
+		case BatchPending:
+			pending = append(pending, &progress.Batches[i])
+		case BatchFailed:
+			failed++
+		}
+	}
+
+	// Start up to MaxParallelBatches child workflows, then start the next batch as each one finishes.
+	next, inFlight := 0, 0
+	for ; next < len(pending) && inFlight < request.MaxParallelBatches; next++ {
+		startBatch(pending[next])
+		inFlight++
+	}
+
+	// Wait for the child workflows to complete, applying the failure policy as they finish. Once the
+	// history grows past the threshold or the job is canceled no new batches are started. Canceling
+	// the job cancels childCtx, so the loop drains as each child acknowledges its cancellation.
+	continueAsNew := false
+	for inFlight > 0 {
+		var index int
+		doneCh.Receive(ctx, &index)
+		inFlight--
+
+		batch := progress.Batches[index]
+		if batch.Status == BatchFailed {
+			failed++
+			logger.Warn("Batch failed", "Frames", batch.Frames.String(), "Error", batch.Error)
+
+			if ctx.Err() == nil && request.exceedsFailurePolicy(failed) {
+				progress.Failed = true
+				cancelChildren()
+
+				// Children still running when the job closes are terminated, so wait for the canceled
+				// batches to remove their workspaces first.
+				if workflow.GetVersion(ctx, failFastCancelChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion {
+					for ; inFlight > 0; inFlight-- {
+						doneCh.Receive(ctx, &index)
+					}
+				}
+
+				return BlenderFarmWorkflowOutput{}, temporal.NewApplicationError(
+					fmt.Sprintf("%d of %d batches failed", failed, len(progress.Batches)),
+					ErrBatchesFailed,
+					progress.Results(),
+				)
 			}
-			resultCh.Send(ctx, childWorkflowOutput)
+		}
+
+		if canContinueAsNew && workflow.GetInfo(ctx).GetCurrentHistoryLength() >= request.HistoryThreshold {
+			continueAsNew = true
+		}
+
+		if ctx.Err() == nil && !continueAsNew && next < len(pending) {
+			startBatch(pending[next])
+			next++
+			inFlight++
+		}
+	}
+
+	if ctx.Err() != nil {
+		return BlenderFarmWorkflowOutput{}, cancelJob(ctx, &progress)
+	}
+
+	if next < len(pending) {
+		logger.Info("Continuing as new", "HistoryLength", workflow.GetInfo(ctx).GetCurrentHistoryLength(), "PendingBatches", len(pending)-next)
+
+		// Only the outcome of the finished batches is carried, the state of every batch outgrows the
+		// payload size limit for jobs with thousands of batches.
+		if workflow.GetVersion(ctx, compactStateChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+			request.Progress = &progress
+		} else {
+			request.Progress = nil
+			request.State = progress.State()
+			request.State.SteppedBatches = steppedBatches
+		}
+
+		// ctx carries the child workflow task queue, so continue on the queue this run was started on.
+		canCtx := workflow.WithWorkflowTaskQueue(ctx, workflow.GetInfo(ctx).TaskQueueName)
+		return BlenderFarmWorkflowOutput{}, workflow.NewContinueAsNewError(canCtx, WorkflowType, request)
+	}
+
+	return BlenderFarmWorkflowOutput{Results: progress.Results()}, nil
+}
+
+// locateArtifact returns the task queues of the nodes that have the artifact cached. Routing is
+// best effort, so batches go to any node when the nodes cannot be located.
+func locateArtifact(ctx workflow.Context, artifact string) []string {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval: time.Second,
+			MaximumAttempts: 3,
+		},
+	})
+
+	var routingAct *commanderactivities.RoutingActivities
+	var queues []string
+	err := workflow.ExecuteActivity(ctx, routingAct.LocateArtifact, artifact).Get(ctx, &queues)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("Unable to locate artifact", "Artifact", artifact, "Error", err.Error())
+		return nil
+	}
+
+	return queues
+}
+
+// cancelJob records the cancellation and its reason in progress, deletes the output artifacts and render
+// logs of batches that finished before the job was canceled and returns the error that closes the job as canceled.
+func cancelJob(ctx workflow.Context, progress *Progress) error {
+	reason := "canceled"
+	var signalReason string
+	if workflow.GetSignalChannel(ctx, CancelSignal).ReceiveAsync(&signalReason) && signalReason != "" {
+		reason = signalReason
+	}
+
+	for i := range progress.Batches {
+		if progress.Batches[i].Status == BatchPending {
+			progress.Batches[i].Status = BatchCanceled
+		}
+	}
+
+	progress.Canceled = true
+	progress.CancelReason = reason
+
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Render canceled", "Reason", reason, "FramesDone", progress.FramesDone(), "FramesTotal", progress.FramesTotal())
+
+	// ctx is canceled, so clean up in a disconnected context.
+	cleanupCtx, _ := workflow.NewDisconnectedContext(ctx)
+	artifacts := progress.OutputArtifacts()
+	if workflow.GetVersion(cleanupCtx, cancelLogCleanupChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion {
+		artifacts = append(artifacts, progress.LogArtifacts()...)
+	}
+
+	if len(artifacts) > 0 && workflow.GetVersion(cleanupCtx, cancelCleanupChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion {
+		cleanupCtx = workflow.WithActivityOptions(cleanupCtx, workflow.ActivityOptions{
+			StartToCloseTimeout: 5 * time.Minute,
+			RetryPolicy: &temporal.RetryPolicy{
+				InitialInterval: time.Second,
+				MaximumAttempts: 5,
+			},
 		})
+
+		var cleanupAct *commanderactivities.CleanupActivities
+		err := workflow.ExecuteActivity(cleanupCtx, cleanupAct.DeleteArtifacts, artifacts).Get(cleanupCtx, nil)
+		if err != nil {
+			logger.Warn("Unable to delete batch artifacts", "Artifacts", artifacts, "Error", err.Error())
+		} else {
+			// The frames stay counted as done, but their artifacts are gone.
+			for i := range progress.Batches {
+				progress.Batches[i].Artifact = ""
+			}
+		}
+	}
+
+	return temporal.NewCanceledError(BlenderFarmWorkflowOutput{
+		Results:      progress.Results(),
+		CancelReason: reason,
+	})
+}
+
+// batchAttempts returns the runs a failed batch took, as reported by the FailureDetails of its last run.
+// A batch that failed without reporting them took one run if it failed permanently, as those failures
+// are never retried, and is otherwise reported with zero attempts as the count is unknown.
+func batchAttempts(err error) int {
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) {
+		return 0
+	}
+
+	var details blendernode.FailureDetails
+	if appErr.HasDetails() && appErr.Details(&details) == nil && details.Attempt > 0 {
+		return details.Attempt
 	}
 
-	// Wait for all child workflows to complete.
-	var results []blendernode.BlenderNodeWorkflowOutput
-	for i := 0; i < numBatches; i++ {
-		var result blendernode.BlenderNodeWorkflowOutput
-		resultCh.Receive(ctx, &result)
-		results = append(results, result)
+	if commanderactivities.IsPermanent(appErr.Type()) {
+		return 1
+	}
+
+	return 0
+}
+
+// ValidateFrames returns an error when spec holds more than MaxFrames frames or splits into more
+// than MaxBatches batches of batchSize frames. It does not expand spec before checking its size.
+func ValidateFrames(spec frames.Spec, batchSize int) error {
+	if spec.Size() > MaxFrames {
+		return fmt.Errorf("frames %q hold more than %d frames", spec.String(), MaxFrames)
 	}
 
-	// Combine the output of all child workflows.
-	var output []string
-	for _, result := range results {
-		// if result.Result == "ERR" {
-		// 	return BlenderFarmWorkflowOutput{Message: "ERR"}, errors.New("one or more child workflows failed")
-		// }
-		output = append(output, result.Result)
+	if batchSize <= 0 {
+		return nil
 	}
 
-	return BlenderFarmWorkflowOutput{Result: output}, nil
+	if batches := (spec.Len() + batchSize - 1) / batchSize; batches > MaxBatches {
+		return fmt.Errorf("frames %q split into %d batches of %d frames, more than %d", spec.String(), batches, batchSize, MaxBatches)
+	}
+
+	return nil
+}
+
+// BatchWorkflowID returns the workflow ID of the child workflow rendering a batch of a job.
+func BatchWorkflowID(parentID string, index int, frameSpec frames.Spec) string {
+	return fmt.Sprintf("%s/batch-%d/frames-%d-%d", parentID, index, frameSpec.First(), frameSpec.Last())
+}
+
+// exceedsFailurePolicy reports whether the number of failed batches should fail the job.
+func (r BlenderFarmWorkflowInput) exceedsFailurePolicy(failed int) bool {
+	switch r.FailurePolicy {
+	case Complete:
+		return false
+	case Tolerate:
+		return failed > r.MaxFailedBatches
+	default:
+		return failed > 0
+	}
 }
@@ -0,0 +1,347 @@
+package blenderfarm
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flowshot-io/commander/pkg/commander/frames"
+	"github.com/flowshot-io/commander/pkg/commander/services/blendernode"
+	commanderactivities "github.com/flowshot-io/commander/pkg/commander/temporalactivities"
+	"github.com/flowshot-io/commander/pkg/commander/workspace"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// sessionCreationActivity is the name of the activity the SDK creates sessions with.
+	sessionCreationActivity = "internalSessionCreationActivity"
+	// jobID is the workflow ID the test environment starts jobs with.
+	jobID = "default-test-workflow-id"
+)
+
+var (
+	artifactAct *commanderactivities.ArtifactActivities
+	blenderAct  *commanderactivities.BlenderActivities
+	wsAct       *commanderactivities.WorkspaceActivities
+	routingAct  *commanderactivities.RoutingActivities
+	cleanupAct  *commanderactivities.CleanupActivities
+)
+
+type WorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(WorkflowTestSuite))
+}
+
+func (s *WorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.SetWorkerOptions(worker.Options{EnableSessionWorker: true})
+	s.env.RegisterWorkflowWithOptions(BlenderFarmWorkflow, workflow.RegisterOptions{Name: WorkflowType})
+	s.env.RegisterWorkflow(blendernode.BlenderNodeWorkflow)
+
+	// No node has the artifact cached, so batches go to any node.
+	s.env.OnActivity(routingAct.LocateArtifact, mock.Anything, "project").Return(nil, nil).Maybe()
+	s.env.OnActivity(wsAct.CreateWorkspace, mock.Anything, mock.Anything, "project").
+		Return(func(ctx context.Context, id string, artifactName string) (workspace.Workspace, error) {
+			return workspace.Workspace{ID: id, Dir: "/workspaces/" + id, Queue: "node-1"}, nil
+		}).Maybe()
+	s.env.OnActivity(wsAct.RemoveWorkspace, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	s.env.OnActivity(artifactAct.PullArtifact, mock.Anything, "project", mock.Anything).Return(nil).Maybe()
+	s.env.OnActivity(artifactAct.PushArtifact, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
+func (s *WorkflowTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+// mockRender renders every batch successfully.
+func (s *WorkflowTestSuite) mockRender() {
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("/output", nil)
+}
+
+func (s *WorkflowTestSuite) render(input BlenderFarmWorkflowInput) BlenderFarmWorkflowOutput {
+	s.env.ExecuteWorkflow(BlenderFarmWorkflow, input)
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	var output BlenderFarmWorkflowOutput
+	s.NoError(s.env.GetWorkflowResult(&output))
+	return output
+}
+
+// outputArtifact returns the name of the output artifact of the batch of the job rendering frameSpec of "project".
+func outputArtifact(frameSpec string) string {
+	return "project-" + jobID + "-" + frameSpec
+}
+
+// resultFrames returns the frames of every result in the order they were returned.
+func resultFrames(results []BatchResult) []string {
+	var specs []string
+	for _, r := range results {
+		specs = append(specs, r.Frames.String())
+	}
+
+	return specs
+}
+
+func (s *WorkflowTestSuite) TestBatchSizeNotSet() {
+	s.mockRender()
+
+	output := s.render(BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 1, EndFrame: 10})
+
+	s.Equal([]string{"1-10"}, resultFrames(output.Results))
+	s.Equal(outputArtifact("1-10"), output.Results[0].Artifact)
+}
+
+func (s *WorkflowTestSuite) TestBatchSizeLargerThanRange() {
+	s.mockRender()
+
+	output := s.render(BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 1, EndFrame: 10, BatchSize: 25})
+
+	s.Equal([]string{"1-10"}, resultFrames(output.Results))
+}
+
+func (s *WorkflowTestSuite) TestUnevenBatches() {
+	s.mockRender()
+
+	output := s.render(BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 1, EndFrame: 10, BatchSize: 4})
+
+	s.Equal([]string{"1-4", "5-8", "9-10"}, resultFrames(output.Results))
+	for _, r := range output.Results {
+		s.Equal(outputArtifact(r.Frames.String()), r.Artifact)
+		s.Empty(r.Error)
+		s.Equal(1, r.Attempts)
+	}
+}
+
+func (s *WorkflowTestSuite) TestSingleFrame() {
+	s.mockRender()
+
+	output := s.render(BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 7, EndFrame: 7, BatchSize: 3})
+
+	s.Equal([]string{"7"}, resultFrames(output.Results))
+	s.Equal(outputArtifact("7"), output.Results[0].Artifact)
+}
+
+func (s *WorkflowTestSuite) TestSteppedBatches() {
+	s.mockRender()
+
+	output := s.render(BlenderFarmWorkflowInput{Artifact: "project", Frames: frames.Spec{{Start: 1, End: 30, Step: 5}}, BatchSize: 3})
+
+	s.Equal([]string{"1-11x5", "16-26x5"}, resultFrames(output.Results))
+	s.Equal(outputArtifact("1-11x5"), output.Results[0].Artifact)
+}
+
+func (s *WorkflowTestSuite) TestSteppedBatchesCarriedAcrossContinueAsNew() {
+	s.mockRender()
+	s.env.SetCurrentHistoryLength(100)
+
+	s.env.ExecuteWorkflow(BlenderFarmWorkflow, BlenderFarmWorkflowInput{Artifact: "project", Frames: frames.Spec{{Start: 1, End: 30, Step: 5}}, BatchSize: 3, MaxParallelBatches: 1, HistoryThreshold: 50})
+
+	s.True(s.env.IsWorkflowCompleted())
+
+	var canErr *workflow.ContinueAsNewError
+	s.True(errors.As(s.env.GetWorkflowError(), &canErr))
+
+	var request BlenderFarmWorkflowInput
+	s.NoError(converter.GetDefaultDataConverter().FromPayloads(canErr.Input, &request))
+	s.True(request.State.SteppedBatches)
+}
+
+func (s *WorkflowTestSuite) TestSteppedBatchesNotCarried() {
+	s.mockRender()
+
+	// Runs continuing a job that started before batches kept the step split the frames into contiguous runs.
+	output := s.render(BlenderFarmWorkflowInput{Artifact: "project", Frames: frames.Spec{{Start: 1, End: 30, Step: 5}}, BatchSize: 3, State: &State{}})
+
+	s.Equal([]string{"1,6,11", "16,21,26"}, resultFrames(output.Results))
+}
+
+func (s *WorkflowTestSuite) TestNoFrames() {
+	s.env.ExecuteWorkflow(BlenderFarmWorkflow, BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 5, EndFrame: 1})
+
+	s.True(s.env.IsWorkflowCompleted())
+
+	var appErr *temporal.ApplicationError
+	s.True(errors.As(s.env.GetWorkflowError(), &appErr))
+	s.Equal(ErrNoFrames, appErr.Type())
+}
+
+func (s *WorkflowTestSuite) TestTooManyFrames() {
+	s.env.ExecuteWorkflow(BlenderFarmWorkflow, BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 1, EndFrame: MaxFrames + 1})
+
+	s.True(s.env.IsWorkflowCompleted())
+
+	var appErr *temporal.ApplicationError
+	s.True(errors.As(s.env.GetWorkflowError(), &appErr))
+	s.Equal(ErrTooManyFrames, appErr.Type())
+}
+
+func (s *WorkflowTestSuite) TestChildFailureFailsFast() {
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, frames.Contiguous(5, 8), mock.Anything, mock.Anything).
+		Return("", temporal.NewNonRetryableApplicationError("project is corrupt", commanderactivities.ErrCorruptProject, nil))
+	s.mockRender()
+
+	s.env.ExecuteWorkflow(BlenderFarmWorkflow, BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 1, EndFrame: 10, BatchSize: 4, MaxParallelBatches: 1})
+
+	s.True(s.env.IsWorkflowCompleted())
+
+	var appErr *temporal.ApplicationError
+	s.True(errors.As(s.env.GetWorkflowError(), &appErr))
+	s.Equal(ErrBatchesFailed, appErr.Type())
+
+	// The batch after the failed one is never started.
+	var results []BatchResult
+	s.NoError(appErr.Details(&results))
+	s.Equal([]string{"1-4", "5-8", "9-10"}, resultFrames(results))
+	s.Empty(results[0].Error)
+	s.Contains(results[1].Error, "project is corrupt")
+	s.Equal(1, results[1].Attempts)
+	s.Empty(results[2].Artifact)
+}
+
+func (s *WorkflowTestSuite) TestChildFailureCancelsRunningBatches() {
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, frames.Contiguous(1, 4), mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, workingDir string, frameSpec frames.Spec, renderer string, slotTimeout time.Duration) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		})
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, frames.Contiguous(5, 8), mock.Anything, mock.Anything).
+		Return("", temporal.NewNonRetryableApplicationError("project is corrupt", commanderactivities.ErrCorruptProject, nil))
+
+	var removed int32
+	s.env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		if info.ActivityType.Name == "RemoveWorkspace" {
+			atomic.AddInt32(&removed, 1)
+		}
+	})
+
+	s.env.ExecuteWorkflow(BlenderFarmWorkflow, BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 1, EndFrame: 8, BatchSize: 4})
+
+	s.True(s.env.IsWorkflowCompleted())
+
+	var appErr *temporal.ApplicationError
+	s.True(errors.As(s.env.GetWorkflowError(), &appErr))
+	s.Equal(ErrBatchesFailed, appErr.Type())
+
+	// The job waits for the running batch to be canceled, which removes its workspace.
+	var results []BatchResult
+	s.NoError(appErr.Details(&results))
+	s.Contains(results[0].Error, "canceled")
+	s.Equal(int32(2), atomic.LoadInt32(&removed))
+}
+
+func (s *WorkflowTestSuite) TestChildFailureCompletes() {
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, frames.Contiguous(5, 8), mock.Anything, mock.Anything).
+		Return("", temporal.NewNonRetryableApplicationError("project is corrupt", commanderactivities.ErrCorruptProject, nil))
+	s.mockRender()
+
+	output := s.render(BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 1, EndFrame: 10, BatchSize: 4, FailurePolicy: Complete})
+
+	s.Equal([]string{"1-4", "5-8", "9-10"}, resultFrames(output.Results))
+	s.Equal(outputArtifact("1-4"), output.Results[0].Artifact)
+	s.Contains(output.Results[1].Error, "project is corrupt")
+	s.Equal(outputArtifact("9-10"), output.Results[2].Artifact)
+}
+
+func (s *WorkflowTestSuite) TestSessionCreationFailure() {
+	s.env.OnActivity(sessionCreationActivity, mock.Anything, mock.Anything).Return(errors.New("no node available"))
+
+	output := s.render(BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 1, EndFrame: 10, FailurePolicy: Complete, MaxBatchAttempts: 1})
+
+	s.Equal([]string{"1-10"}, resultFrames(output.Results))
+	s.Contains(output.Results[0].Error, "no node available")
+	s.Equal(1, output.Results[0].Attempts)
+}
+
+func (s *WorkflowTestSuite) TestCancel() {
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, workingDir string, frameSpec frames.Spec, renderer string, slotTimeout time.Duration) (string, error) {
+			s.env.SignalWorkflow(CancelSignal, "superseded")
+			s.env.CancelWorkflow()
+			<-ctx.Done()
+			return "", ctx.Err()
+		})
+
+	s.env.ExecuteWorkflow(BlenderFarmWorkflow, BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 1, EndFrame: 10})
+
+	s.True(s.env.IsWorkflowCompleted())
+
+	var canceledErr *temporal.CanceledError
+	s.True(errors.As(s.env.GetWorkflowError(), &canceledErr))
+
+	var output BlenderFarmWorkflowOutput
+	s.NoError(canceledErr.Details(&output))
+	s.Equal("superseded", output.CancelReason)
+	s.Empty(output.Results[0].Artifact)
+}
+
+func (s *WorkflowTestSuite) TestCancelDeletesArtifacts() {
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, frames.Contiguous(1, 4), mock.Anything, mock.Anything).Return("/output", nil)
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, frames.Contiguous(5, 8), mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, workingDir string, frameSpec frames.Spec, renderer string, slotTimeout time.Duration) (string, error) {
+			s.env.CancelWorkflow()
+			<-ctx.Done()
+			return "", ctx.Err()
+		})
+	s.env.OnActivity(cleanupAct.DeleteArtifacts, mock.Anything, []string{outputArtifact("1-4"), blendernode.LogArtifactName(jobID, "project", frames.Contiguous(1, 4))}).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(BlenderFarmWorkflow, BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 1, EndFrame: 8, BatchSize: 4, MaxParallelBatches: 1})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(temporal.IsCanceledError(s.env.GetWorkflowError()))
+}
+
+func (s *WorkflowTestSuite) TestJobsRenderingTheSameFramesKeepTheirArtifacts() {
+	// Both jobs render frames 1-8 of the same artifact, the first to completion and the second until canceled.
+	s.mockRender()
+	s.env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: "job-1"})
+
+	output := s.render(BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 1, EndFrame: 8, BatchSize: 4})
+	s.Equal("project-job-1-1-4", output.Results[0].Artifact)
+	s.Equal("project-job-1-5-8", output.Results[1].Artifact)
+	s.env.AssertExpectations(s.T())
+
+	s.SetupTest()
+	s.env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: "job-2"})
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, frames.Contiguous(1, 4), mock.Anything, mock.Anything).Return("/output", nil)
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, frames.Contiguous(5, 8), mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, workingDir string, frameSpec frames.Spec, renderer string, slotTimeout time.Duration) (string, error) {
+			s.env.CancelWorkflow()
+			<-ctx.Done()
+			return "", ctx.Err()
+		})
+
+	// Canceling the second job only deletes its own artifacts.
+	s.env.OnActivity(cleanupAct.DeleteArtifacts, mock.Anything, []string{"project-job-2-1-4", "project-job-2-1-4-log"}).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(BlenderFarmWorkflow, BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 1, EndFrame: 8, BatchSize: 4, MaxParallelBatches: 1})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(temporal.IsCanceledError(s.env.GetWorkflowError()))
+}
+
+func (s *WorkflowTestSuite) TestArtifactsBeforeJobArtifacts() {
+	// Jobs started before artifacts were named after the job keep naming them after the artifact and frames.
+	s.env.OnGetVersion(jobArtifactsChange, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	s.mockRender()
+
+	output := s.render(BlenderFarmWorkflowInput{Artifact: "project", StartFrame: 1, EndFrame: 10})
+
+	s.Equal("project-1-10", output.Results[0].Artifact)
+}
@@ -1,32 +1,76 @@
 package blendernode
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"time"
 
+	"github.com/flowshot-io/commander/pkg/commander/artifactcache"
+	"github.com/flowshot-io/commander/pkg/commander/noderegistry"
+	"github.com/flowshot-io/commander/pkg/commander/renderer"
+	"github.com/flowshot-io/commander/pkg/commander/renderoutput"
 	commanderactivities "github.com/flowshot-io/commander/pkg/commander/temporalactivities"
+	"github.com/flowshot-io/commander/pkg/commander/workspace"
+	"github.com/flowshot-io/polystore/pkg/types"
 	"github.com/flowshot-io/x/pkg/artifactservice"
 	"github.com/flowshot-io/x/pkg/logger"
 	"github.com/flowshot-io/x/pkg/manager"
 	"github.com/flowshot-io/x/pkg/temporalactivities"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
 )
 
-const Queue = "blendernode-queue"
+const (
+	Queue = "blendernode-queue"
+
+	// reportInterval is how often the node reports its cached artifacts to the node registry.
+	reportInterval = 30 * time.Second
+)
 
 type (
 	Options struct {
 		TemporalClient client.Client
 		ArtifactClient artifactservice.ArtifactServiceClient
-		Logger         logger.Logger
+		// ArtifactStore is the storage ArtifactClient keeps artifacts in, read by the node's artifact cache.
+		ArtifactStore types.Storage
+		Logger        logger.Logger
+		// CacheDir and CacheMaxSize configure the node's artifact cache.
+		CacheDir     string
+		CacheMaxSize int64
+		// Host names the node's task queue, it defaults to the hostname.
+		Host string
+		// WorkspaceRoot, WorkspaceMinFree and WorkspaceKeepFailedFor configure the node's workspace manager.
+		WorkspaceRoot          string
+		WorkspaceMinFree       int64
+		WorkspaceKeepFailedFor time.Duration
+		// WorkspaceReservation is the disk space reserved for a project that is not cached on the node.
+		WorkspaceReservation int64
+		// Renderer is used for jobs that do not choose one, it defaults to renderer.Default.
+		Renderer        string
+		RendererOptions renderer.Options
+		// AllowBlankFrames accepts rendered frames that are fully black or transparent.
+		AllowBlankFrames bool
 	}
 
 	Service struct {
-		logger logger.Logger
-		worker worker.Worker
+		logger         logger.Logger
+		temporalClient client.Client
+		cache          *artifactcache.Cache
+		host           string
+		worker         worker.Worker
+		hostWorker     worker.Worker
+		cancel         context.CancelFunc
+		done           chan struct{}
 	}
 )
 
+// HostQueue returns the task queue polled only by the blendernode on host.
+func HostQueue(host string) string {
+	return Queue + "@" + host
+}
+
 func New(opts Options) (manager.Service, error) {
 	if opts.Logger == nil {
 		opts.Logger = logger.NoOp()
@@ -40,18 +84,92 @@ func New(opts Options) (manager.Service, error) {
 		return nil, fmt.Errorf("artifact client is required")
 	}
 
-	worker := worker.New(opts.TemporalClient, Queue, worker.Options{
-		EnableSessionWorker:               true,
-		MaxConcurrentSessionExecutionSize: 1,
+	if opts.ArtifactStore == nil {
+		return nil, fmt.Errorf("artifact store is required")
+	}
+
+	if opts.Host == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get hostname: %w", err)
+		}
+
+		opts.Host = host
+	}
+
+	if opts.Renderer == "" {
+		opts.Renderer = renderer.Default
+	}
+
+	renderers := map[string]renderer.Renderer{}
+	for _, name := range renderer.Names {
+		r, err := renderer.New(name, opts.RendererOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		renderers[name] = r
+	}
+
+	if _, ok := renderers[opts.Renderer]; !ok {
+		return nil, fmt.Errorf("unknown renderer: %q", opts.Renderer)
+	}
+
+	cache, err := artifactcache.New(artifactcache.Options{
+		Store:   opts.ArtifactStore,
+		Logger:  opts.Logger,
+		Dir:     opts.CacheDir,
+		MaxSize: opts.CacheMaxSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	workspaces, err := workspace.New(workspace.Options{
+		Logger:        opts.Logger,
+		Root:          opts.WorkspaceRoot,
+		MinFree:       opts.WorkspaceMinFree,
+		KeepFailedFor: opts.WorkspaceKeepFailedFor,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The activities are shared by both workers so the node still renders one batch at a time.
+	blenderActivities := commanderactivities.NewBlenderActivities(opts.TemporalClient, renderers, opts.Renderer, renderoutput.Options{
+		AllowBlank: opts.AllowBlankFrames,
 	})
+	artifactActivities := commanderactivities.NewArtifactActivities(opts.ArtifactClient, cache, workspaces)
+	fsActivities := temporalactivities.NewFSActivities()
+	workspaceActivities := commanderactivities.NewWorkspaceActivities(workspaces, cache, HostQueue(opts.Host), opts.WorkspaceReservation)
 
-	worker.RegisterWorkflow(BlenderNodeWorkflow)
-	worker.RegisterActivity(commanderactivities.NewBlenderActivities())
-	worker.RegisterActivity(temporalactivities.NewArtifactActivities(opts.ArtifactClient))
+	// The shared worker takes batches any node can render, the host worker takes
+	// batches routed to this node because it has their project cached.
+	newWorker := func(taskQueue string) worker.Worker {
+		w := worker.New(opts.TemporalClient, taskQueue, worker.Options{
+			EnableSessionWorker:               true,
+			MaxConcurrentSessionExecutionSize: 1,
+		})
+
+		w.RegisterWorkflow(BlenderNodeWorkflow)
+		w.RegisterActivity(blenderActivities)
+		w.RegisterActivity(artifactActivities)
+		w.RegisterActivity(fsActivities)
+		w.RegisterActivity(workspaceActivities)
+
+		return w
+	}
+
+	sharedWorker := newWorker(Queue)
+	sharedWorker.RegisterWorkflowWithOptions(noderegistry.RegistryWorkflow, workflow.RegisterOptions{Name: noderegistry.WorkflowType})
 
 	return &Service{
-		worker: worker,
-		logger: opts.Logger,
+		logger:         opts.Logger,
+		temporalClient: opts.TemporalClient,
+		cache:          cache,
+		host:           opts.Host,
+		worker:         sharedWorker,
+		hostWorker:     newWorker(HostQueue(opts.Host)),
 	}, nil
 }
 
@@ -62,10 +180,54 @@ func (s *Service) Start() error {
 		return err
 	}
 
+	err = s.hostWorker.Start()
+	if err != nil {
+		s.worker.Stop()
+		s.logger.Error("Unable to start host worker", map[string]interface{}{"Error": err.Error()})
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.report(ctx)
+
 	return nil
 }
 
 func (s *Service) Stop() error {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+	}
+
+	s.hostWorker.Stop()
 	s.worker.Stop()
 	return nil
 }
+
+// report sends the node's cached artifacts to the node registry every reportInterval until ctx is done.
+func (s *Service) report(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(reportInterval)
+	defer ticker.Stop()
+
+	for {
+		node := noderegistry.Node{
+			Host:      s.host,
+			Queue:     HostQueue(s.host),
+			Artifacts: s.cache.Artifacts(),
+		}
+
+		if err := noderegistry.Report(ctx, s.temporalClient, Queue, node); err != nil && ctx.Err() == nil {
+			s.logger.Warn("Unable to report to node registry", map[string]interface{}{"Error": err.Error()})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
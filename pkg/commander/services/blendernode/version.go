@@ -0,0 +1,21 @@
+package blendernode
+
+// Change IDs of BlenderNodeWorkflow passed to workflow.GetVersion. A batch holds its session on
+// one node until the render finishes, so the node may be upgraded under it; the changes below keep
+// those batches on the commands they started with.
+const (
+	// frameSpecChange names output artifacts after the frame spec instead of the start and end frame.
+	frameSpecChange = "frame-spec"
+	// workspaceCleanupChange removes the local working directory once the render finishes.
+	workspaceCleanupChange = "workspace-cleanup"
+	// workspaceManagerChange creates the workspace through the node's workspace manager and removes it
+	// after the session completes.
+	workspaceManagerChange = "workspace-manager"
+	// renderLogChange pushes the render log of the batch to the artifact store once the render finishes.
+	renderLogChange = "render-log"
+	// busyNodeFallbackChange bounds the wait for a busy preferred node and falls back to any node.
+	busyNodeFallbackChange = "busy-node-fallback"
+	// renderFramesChange schedules RenderFramesActivity with the frame spec, renderer and slot timeout
+	// instead of RenderProjectActivity with the start and end frame.
+	renderFramesChange = "render-frames"
+)
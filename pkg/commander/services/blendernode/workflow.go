@@ -1,88 +1,344 @@
 package blendernode
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"time"
 
+	"github.com/flowshot-io/commander/pkg/commander/frames"
+	"github.com/flowshot-io/commander/pkg/commander/renderlog"
 	commanderactivities "github.com/flowshot-io/commander/pkg/commander/temporalactivities"
+	"github.com/flowshot-io/commander/pkg/commander/workspace"
 	"github.com/flowshot-io/x/pkg/temporalactivities"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
-const Query = "blendernode-query"
+const (
+	// Query returns the renderlog.Progress of the render.
+	Query = "blendernode-query"
+
+	// DefaultMaxActivityAttempts is used when a render does not set MaxActivityAttempts.
+	DefaultMaxActivityAttempts = 5
+
+	// ErrRenderFailed is the application error type of a failed render whose cause is not an application error.
+	ErrRenderFailed = "RenderFailed"
+)
 
 type (
 	BlenderNodeWorkflowInput struct {
-		Artifact   string
+		Artifact string
+		Frames   frames.Spec
+		// Job is the workflow ID of the job the batch belongs to. When set, the artifacts of the batch are
+		// named after it, so jobs rendering the same frames of an artifact do not share them.
+		Job string
+		// StartFrame and EndFrame are only set by executions started before Frames was introduced.
 		StartFrame int
 		EndFrame   int
+		// PreferredQueue is the HostQueue of a node that has the artifact cached. The render
+		// falls back to any node when no session is created there within PreferredQueueTimeout.
+		PreferredQueue        string
+		PreferredQueueTimeout time.Duration
+		// Renderer overrides the node's default renderer when set.
+		Renderer string
+		// MaxActivityAttempts caps the attempts of each activity of the render.
+		MaxActivityAttempts int
 	}
 
 	BlenderNodeWorkflowOutput struct {
-		Result string
+		Result  string
+		Node    string
+		Attempt int
+	}
+
+	// FailureDetails are the details of the error a failed render returns.
+	FailureDetails struct {
+		// Attempt is the run of the workflow that failed, counting from 1.
+		Attempt int
 	}
 )
 
 func BlenderNodeWorkflow(ctx workflow.Context, request BlenderNodeWorkflowInput) (BlenderNodeWorkflowOutput, error) {
+	if request.MaxActivityAttempts <= 0 {
+		request.MaxActivityAttempts = DefaultMaxActivityAttempts
+	}
+
 	ao := workflow.ActivityOptions{
 		StartToCloseTimeout: 60 * time.Minute,
 		HeartbeatTimeout:    1 * time.Minute,
+		// Wait for a canceled render to stop rocketblend before the workflow reports the cancellation.
+		WaitForCancellation: true,
 		RetryPolicy: &temporal.RetryPolicy{
-			InitialInterval:    time.Second,
-			BackoffCoefficient: 2.0,
-			MaximumInterval:    time.Minute,
+			InitialInterval:        time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        time.Minute,
+			MaximumAttempts:        int32(request.MaxActivityAttempts),
+			NonRetryableErrorTypes: commanderactivities.PermanentErrors,
 		},
 	}
 	ctx = workflow.WithActivityOptions(ctx, ao)
 
+	if len(request.Frames) == 0 {
+		request.Frames = frames.Contiguous(request.StartFrame, request.EndFrame)
+	}
+
 	logger := workflow.GetLogger(ctx)
-	logger.Info("Render started", "Artifact", request.Artifact, "StartFrame", request.StartFrame, "EndFrame", request.EndFrame)
+	logger.Info("Render started", "Artifact", request.Artifact, "Frames", request.Frames.String())
 
-	outputArtifact, err := renderProjectArtifact(ctx, request.Artifact, request.StartFrame, request.EndFrame)
+	// RenderFramesActivity signals the progress it reads from the render log.
+	progress := renderlog.Progress{FramesTotal: request.Frames.Len()}
+	err := workflow.SetQueryHandler(ctx, Query, func() (renderlog.Progress, error) {
+		return progress, nil
+	})
+	if err != nil {
+		return BlenderNodeWorkflowOutput{}, err
+	}
+
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		progressCh := workflow.GetSignalChannel(ctx, commanderactivities.RenderProgressSignal)
+		for {
+			progressCh.Receive(ctx, &progress)
+		}
+	})
+
+	output, err := renderProjectArtifact(ctx, request)
 	if err != nil {
 		logger.Error("Workflow failed.", "Error", err.Error())
-		return BlenderNodeWorkflowOutput{Result: "ERR"}, err
+		return BlenderNodeWorkflowOutput{Result: "ERR"}, renderFailure(ctx, err)
 	}
 
-	logger.Info("Workflow completed.")
+	output.Attempt = int(workflow.GetInfo(ctx).Attempt)
+	logger.Info("Workflow completed.", "Node", output.Node)
 
-	return BlenderNodeWorkflowOutput{Result: outputArtifact}, nil
+	return output, nil
 }
 
-func renderProjectArtifact(ctx workflow.Context, projectArtifact string, startFrame int, endFrame int) (string, error) {
-	so := &workflow.SessionOptions{
-		CreationTimeout:  30 * time.Minute,
-		ExecutionTimeout: 60 * time.Minute,
-		HeartbeatTimeout: 1 * time.Minute,
+func renderProjectArtifact(ctx workflow.Context, request BlenderNodeWorkflowInput) (BlenderNodeWorkflowOutput, error) {
+	sessionCtx, preferred, err := createSession(ctx, request.PreferredQueue, request.PreferredQueueTimeout)
+	if err != nil {
+		return BlenderNodeWorkflowOutput{}, err
 	}
-	sessionCtx, err := workflow.CreateSession(ctx, so)
+
+	// A node's host queue accepts a session while the node renders a batch taken from the shared queue,
+	// so on the preferred node the render only waits PreferredQueueTimeout for the node to free up.
+	if !preferred || workflow.GetVersion(ctx, busyNodeFallbackChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return renderInSession(ctx, sessionCtx, request, 0)
+	}
+
+	output, err := renderInSession(ctx, sessionCtx, request, request.PreferredQueueTimeout)
+	if !isNodeBusy(err) {
+		return output, err
+	}
+
+	workflow.GetLogger(ctx).Info("Preferred node busy, falling back to any node.", "Queue", request.PreferredQueue)
+
+	sessionCtx, _, err = createSession(ctx, "", 0)
 	if err != nil {
-		return "", err
+		return BlenderNodeWorkflowOutput{}, err
+	}
+
+	return renderInSession(ctx, sessionCtx, request, 0)
+}
+
+// renderInSession renders the batch on the node of sessionCtx and completes the session. The render waits
+// at most slotTimeout for another render on the node to finish, or as long as it takes when it is zero.
+func renderInSession(ctx workflow.Context, sessionCtx workflow.Context, request BlenderNodeWorkflowInput, slotTimeout time.Duration) (output BlenderNodeWorkflowOutput, err error) {
+	projectArtifact, frameSpec := request.Artifact, request.Frames
+
+	// A managed workspace is removed once the session has completed, so the node can take its next batch
+	// while the workspace is deleted. The defer is registered before CompleteSession so it runs after it.
+	managedWorkspace := workflow.GetVersion(ctx, workspaceManagerChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion
+	var ws workspace.Workspace
+	if managedWorkspace {
+		defer func() {
+			removeManagedWorkspace(ctx, ws, err != nil && !temporal.IsCanceledError(err) && !isNodeBusy(err))
+		}()
 	}
 	defer workflow.CompleteSession(sessionCtx)
 
+	node := workflow.GetSessionInfo(sessionCtx).HostName
 	localDir := filepath.Join("temp", workflow.GetInfo(ctx).WorkflowExecution.ID)
 
-	var artifactAct *temporalactivities.ArtifactActivities
+	if managedWorkspace {
+		var wsAct *commanderactivities.WorkspaceActivities
+		err = workflow.ExecuteActivity(sessionCtx, wsAct.CreateWorkspace, workflow.GetInfo(ctx).WorkflowExecution.ID, projectArtifact).Get(sessionCtx, &ws)
+		if err != nil {
+			return BlenderNodeWorkflowOutput{}, err
+		}
+
+		localDir = ws.Dir
+	} else {
+		// Deferred after CompleteSession so it runs first, while the session is still open.
+		defer removeWorkspace(sessionCtx, localDir)
+	}
+
+	var artifactAct *commanderactivities.ArtifactActivities
 	err = workflow.ExecuteActivity(sessionCtx, artifactAct.PullArtifact, projectArtifact, localDir).Get(sessionCtx, nil)
 	if err != nil {
-		return "", err
+		return BlenderNodeWorkflowOutput{}, err
 	}
 
 	var blenderAct *commanderactivities.BlenderActivities
 	var outputDir string
-	err = workflow.ExecuteActivity(sessionCtx, blenderAct.RenderProjectActivity, localDir, startFrame, endFrame).Get(sessionCtx, &outputDir)
+	if workflow.GetVersion(ctx, renderFramesChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		err = workflow.ExecuteActivity(sessionCtx, blenderAct.RenderProjectActivity, localDir, frameSpec.First(), frameSpec.Last()).Get(sessionCtx, &outputDir)
+	} else {
+		err = workflow.ExecuteActivity(sessionCtx, blenderAct.RenderFramesActivity, localDir, frameSpec, request.Renderer, slotTimeout).Get(sessionCtx, &outputDir)
+	}
+	if workflow.GetVersion(ctx, renderLogChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion && !temporal.IsCanceledError(err) && !isNodeBusy(err) {
+		pushRenderLog(sessionCtx, LogArtifactName(request.Job, projectArtifact, frameSpec), filepath.Join(localDir, renderlog.Dir))
+	}
 	if err != nil {
-		return "", err
+		return BlenderNodeWorkflowOutput{}, err
+	}
+
+	outputArtifactName := OutputArtifactName(request.Job, projectArtifact, frameSpec)
+	if workflow.GetVersion(ctx, frameSpecChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		outputArtifactName = fmt.Sprintf("%s-%d-%d", projectArtifact, frameSpec.First(), frameSpec.Last())
 	}
 
-	outputArtifactName := fmt.Sprintf("%s-%d-%d", projectArtifact, startFrame, endFrame)
 	err = workflow.ExecuteActivity(sessionCtx, artifactAct.PushArtifact, outputArtifactName, []string{outputDir}).Get(sessionCtx, nil)
 	if err != nil {
-		return "", err
+		return BlenderNodeWorkflowOutput{}, err
+	}
+
+	return BlenderNodeWorkflowOutput{Result: outputArtifactName, Node: node}, nil
+}
+
+// renderFailure returns the error the workflow fails with, carrying the attempt of the run as FailureDetails.
+// An activity failure in commanderactivities.PermanentErrors is returned as non-retryable, as the retry
+// policy of the batch only sees the type of the error the workflow returns.
+func renderFailure(ctx workflow.Context, err error) error {
+	if temporal.IsCanceledError(err) {
+		return err
+	}
+
+	details := FailureDetails{Attempt: int(workflow.GetInfo(ctx).Attempt)}
+
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) {
+		return temporal.NewApplicationErrorWithCause(err.Error(), ErrRenderFailed, err, details)
+	}
+
+	if commanderactivities.IsPermanent(appErr.Type()) {
+		return temporal.NewNonRetryableApplicationError(appErr.Message(), appErr.Type(), err, details)
+	}
+
+	return temporal.NewApplicationErrorWithCause(appErr.Message(), appErr.Type(), err, details)
+}
+
+// OutputArtifactName returns the name of the artifact the output of the batch of job rendering frameSpec of
+// artifact is pushed to. Batches of jobs started before they were named after their job pass an empty job.
+func OutputArtifactName(job string, artifact string, frameSpec frames.Spec) string {
+	if job == "" {
+		return fmt.Sprintf("%s-%s", artifact, frameSpec.String())
+	}
+
+	return fmt.Sprintf("%s-%s-%s", artifact, job, frameSpec.String())
+}
+
+// LogArtifactName returns the name of the artifact the render log of the batch of job rendering frameSpec of
+// artifact is pushed to.
+func LogArtifactName(job string, artifact string, frameSpec frames.Spec) string {
+	return OutputArtifactName(job, artifact, frameSpec) + "-log"
+}
+
+// createSession creates the session the render runs in, on the node polling preferredQueue if
+// one is given and it accepts the session within timeout, otherwise on any node. It reports
+// whether the session is on the preferred node.
+func createSession(ctx workflow.Context, preferredQueue string, timeout time.Duration) (workflow.Context, bool, error) {
+	so := &workflow.SessionOptions{
+		CreationTimeout:  30 * time.Minute,
+		ExecutionTimeout: 60 * time.Minute,
+		HeartbeatTimeout: 1 * time.Minute,
+	}
+
+	if preferredQueue != "" {
+		preferred := *so
+		preferred.CreationTimeout = timeout
+
+		sessionCtx, err := workflow.CreateSession(workflow.WithTaskQueue(ctx, preferredQueue), &preferred)
+		if err == nil {
+			return sessionCtx, true, nil
+		}
+
+		workflow.GetLogger(ctx).Info("Preferred node unavailable, falling back to any node.", "Queue", preferredQueue, "Error", err.Error())
+	}
+
+	sessionCtx, err := workflow.CreateSession(ctx, so)
+	return sessionCtx, false, err
+}
+
+// isNodeBusy reports whether err is the failure of a render that gave up waiting for the node's render slot.
+func isNodeBusy(err error) bool {
+	var appErr *temporal.ApplicationError
+	return errors.As(err, &appErr) && appErr.Type() == commanderactivities.ErrNodeBusy
+}
+
+// pushRenderLog pushes the render log in logDir, whether or not the render succeeded. The log is only
+// kept for debugging, so failing to push it does not fail the render.
+func pushRenderLog(sessionCtx workflow.Context, artifactName string, logDir string) {
+	ctx := workflow.WithActivityOptions(sessionCtx, workflow.ActivityOptions{
+		StartToCloseTimeout: 5 * time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval: time.Second,
+			MaximumAttempts: 3,
+		},
+	})
+
+	var artifactAct *commanderactivities.ArtifactActivities
+	err := workflow.ExecuteActivity(ctx, artifactAct.PushArtifact, artifactName, []string{logDir}).Get(ctx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("Unable to push render log.", "Artifact", artifactName, "Error", err.Error())
 	}
+}
+
+// removeManagedWorkspace removes the workspace from the node it is on through the node's own task queue, as
+// the session has already completed. It runs in a disconnected context so it also runs once canceled.
+func removeManagedWorkspace(ctx workflow.Context, ws workspace.Workspace, failed bool) {
+	if ws.Dir == "" {
+		return
+	}
+
+	ctx, _ = workflow.NewDisconnectedContext(ctx)
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		TaskQueue:           ws.Queue,
+		StartToCloseTimeout: 5 * time.Minute,
+		// The node may have gone away, in which case its workspace root is cleaned up by hand.
+		ScheduleToStartTimeout: 10 * time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval: time.Second,
+			MaximumAttempts: 3,
+		},
+	})
 
-	return outputArtifactName, nil
+	var wsAct *commanderactivities.WorkspaceActivities
+	err := workflow.ExecuteActivity(ctx, wsAct.RemoveWorkspace, ws.ID, failed).Get(ctx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("Unable to remove workspace.", "Dir", ws.Dir, "Error", err.Error())
+	}
+}
+
+// removeWorkspace deletes the local working directory of the render on the session's node. It runs in a
+// disconnected context so the workspace is also removed when the render fails or is canceled.
+func removeWorkspace(sessionCtx workflow.Context, localDir string) {
+	ctx, _ := workflow.NewDisconnectedContext(sessionCtx)
+	if workflow.GetVersion(ctx, workspaceCleanupChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 5 * time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval: time.Second,
+			MaximumAttempts: 3,
+		},
+	})
+
+	var fsAct *temporalactivities.FSActivities
+	err := workflow.ExecuteActivity(ctx, fsAct.RemoveAll, localDir).Get(ctx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("Unable to remove workspace.", "Dir", localDir, "Error", err.Error())
+	}
 }
@@ -0,0 +1,214 @@
+package blendernode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flowshot-io/commander/pkg/commander/frames"
+	commanderactivities "github.com/flowshot-io/commander/pkg/commander/temporalactivities"
+	"github.com/flowshot-io/commander/pkg/commander/workspace"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// sessionCreationActivity is the name of the activity the SDK creates sessions with.
+const sessionCreationActivity = "internalSessionCreationActivity"
+
+var (
+	artifactAct *commanderactivities.ArtifactActivities
+	blenderAct  *commanderactivities.BlenderActivities
+	wsAct       *commanderactivities.WorkspaceActivities
+)
+
+type WorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(WorkflowTestSuite))
+}
+
+func (s *WorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.SetWorkerOptions(worker.Options{EnableSessionWorker: true})
+	s.env.RegisterWorkflow(BlenderNodeWorkflow)
+}
+
+func (s *WorkflowTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+// mockWorkspace mocks the creation and removal of the workspace of the render, expecting it to be removed
+// as failed or not.
+func (s *WorkflowTestSuite) mockWorkspace(failed bool) {
+	ws := workspace.Workspace{ID: "batch", Dir: "/workspaces/batch", Queue: "node-1"}
+	s.env.OnActivity(wsAct.CreateWorkspace, mock.Anything, mock.Anything, "project").Return(ws, nil).Once()
+	s.env.OnActivity(wsAct.RemoveWorkspace, mock.Anything, ws.ID, failed).Return(nil).Once()
+	s.env.OnActivity(artifactAct.PullArtifact, mock.Anything, "project", ws.Dir).Return(nil).Once()
+}
+
+func (s *WorkflowTestSuite) TestRender() {
+	s.mockWorkspace(false)
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, "/workspaces/batch", frames.Contiguous(1, 10), "", mock.Anything).Return("/workspaces/batch/output", nil).Once()
+	s.env.OnActivity(artifactAct.PushArtifact, mock.Anything, LogArtifactName("", "project", frames.Contiguous(1, 10)), mock.Anything).Return(nil).Once()
+	s.env.OnActivity(artifactAct.PushArtifact, mock.Anything, "project-1-10", []string{"/workspaces/batch/output"}).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(BlenderNodeWorkflow, BlenderNodeWorkflowInput{Artifact: "project", Frames: frames.Contiguous(1, 10)})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	var output BlenderNodeWorkflowOutput
+	s.NoError(s.env.GetWorkflowResult(&output))
+	s.Equal("project-1-10", output.Result)
+	s.Equal(1, output.Attempt)
+}
+
+func (s *WorkflowTestSuite) TestRenderJobArtifacts() {
+	// Batches of a job name their artifacts after the job, so jobs rendering the same frames do not share them.
+	s.mockWorkspace(false)
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, "/workspaces/batch", frames.Contiguous(1, 10), "", mock.Anything).Return("/workspaces/batch/output", nil).Once()
+	s.env.OnActivity(artifactAct.PushArtifact, mock.Anything, "project-job-1-10-log", mock.Anything).Return(nil).Once()
+	s.env.OnActivity(artifactAct.PushArtifact, mock.Anything, "project-job-1-10", []string{"/workspaces/batch/output"}).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(BlenderNodeWorkflow, BlenderNodeWorkflowInput{Job: "job", Artifact: "project", Frames: frames.Contiguous(1, 10)})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	var output BlenderNodeWorkflowOutput
+	s.NoError(s.env.GetWorkflowResult(&output))
+	s.Equal("project-job-1-10", output.Result)
+}
+
+func (s *WorkflowTestSuite) TestRenderBeforeRenderFrames() {
+	// Batches started before RenderFramesActivity keep scheduling RenderProjectActivity with the start and end frame.
+	s.env.OnGetVersion(renderFramesChange, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	s.mockWorkspace(false)
+	s.env.OnActivity(blenderAct.RenderProjectActivity, mock.Anything, "/workspaces/batch", 1, 10).Return("/workspaces/batch/output", nil).Once()
+	s.env.OnActivity(artifactAct.PushArtifact, mock.Anything, LogArtifactName("", "project", frames.Contiguous(1, 10)), mock.Anything).Return(nil).Once()
+	s.env.OnActivity(artifactAct.PushArtifact, mock.Anything, "project-1-10", []string{"/workspaces/batch/output"}).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(BlenderNodeWorkflow, BlenderNodeWorkflowInput{Artifact: "project", Frames: frames.Contiguous(1, 10)})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *WorkflowTestSuite) TestRenderPreferredNodeBusy() {
+	// The workspace is created and removed once on the busy preferred node and once on the fallback node.
+	ws := workspace.Workspace{ID: "batch", Dir: "/workspaces/batch", Queue: "node-1"}
+	s.env.OnActivity(wsAct.CreateWorkspace, mock.Anything, mock.Anything, "project").Return(ws, nil).Twice()
+	s.env.OnActivity(wsAct.RemoveWorkspace, mock.Anything, ws.ID, false).Return(nil).Twice()
+	s.env.OnActivity(artifactAct.PullArtifact, mock.Anything, "project", ws.Dir).Return(nil).Twice()
+
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, 5*time.Minute).
+		Return("", temporal.NewNonRetryableApplicationError("node is busy", commanderactivities.ErrNodeBusy, nil)).Once()
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, time.Duration(0)).
+		Return("/workspaces/batch/output", nil).Once()
+	s.env.OnActivity(artifactAct.PushArtifact, mock.Anything, LogArtifactName("", "project", frames.Contiguous(1, 10)), mock.Anything).Return(nil).Once()
+	s.env.OnActivity(artifactAct.PushArtifact, mock.Anything, "project-1-10", mock.Anything).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(BlenderNodeWorkflow, BlenderNodeWorkflowInput{
+		Artifact:              "project",
+		Frames:                frames.Contiguous(1, 10),
+		PreferredQueue:        "node-1",
+		PreferredQueueTimeout: 5 * time.Minute,
+	})
+
+	s.True(s.env.IsWorkflowCompleted())
+
+	var output BlenderNodeWorkflowOutput
+	s.NoError(s.env.GetWorkflowResult(&output))
+	s.Equal("project-1-10", output.Result)
+}
+
+func (s *WorkflowTestSuite) TestRenderSingleFrame() {
+	s.mockWorkspace(false)
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, frames.Contiguous(7, 7), "", mock.Anything).Return("/workspaces/batch/output", nil).Once()
+	s.env.OnActivity(artifactAct.PushArtifact, mock.Anything, LogArtifactName("", "project", frames.Contiguous(7, 7)), mock.Anything).Return(nil).Once()
+	s.env.OnActivity(artifactAct.PushArtifact, mock.Anything, "project-7", mock.Anything).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(BlenderNodeWorkflow, BlenderNodeWorkflowInput{Artifact: "project", StartFrame: 7, EndFrame: 7})
+
+	s.True(s.env.IsWorkflowCompleted())
+
+	var output BlenderNodeWorkflowOutput
+	s.NoError(s.env.GetWorkflowResult(&output))
+	s.Equal("project-7", output.Result)
+}
+
+func (s *WorkflowTestSuite) TestRenderPermanentFailure() {
+	s.mockWorkspace(true)
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return("", temporal.NewNonRetryableApplicationError("project is corrupt", commanderactivities.ErrCorruptProject, nil)).Once()
+	s.env.OnActivity(artifactAct.PushArtifact, mock.Anything, LogArtifactName("", "project", frames.Contiguous(1, 10)), mock.Anything).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(BlenderNodeWorkflow, BlenderNodeWorkflowInput{Artifact: "project", Frames: frames.Contiguous(1, 10)})
+
+	s.True(s.env.IsWorkflowCompleted())
+
+	var appErr *temporal.ApplicationError
+	s.True(errors.As(s.env.GetWorkflowError(), &appErr))
+	s.Equal(commanderactivities.ErrCorruptProject, appErr.Type())
+	s.True(appErr.NonRetryable())
+
+	var details FailureDetails
+	s.NoError(appErr.Details(&details))
+	s.Equal(1, details.Attempt)
+}
+
+func (s *WorkflowTestSuite) TestRenderRetryableFailure() {
+	s.mockWorkspace(true)
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return("", errors.New("blender crashed")).Times(2)
+	s.env.OnActivity(artifactAct.PushArtifact, mock.Anything, LogArtifactName("", "project", frames.Contiguous(1, 10)), mock.Anything).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(BlenderNodeWorkflow, BlenderNodeWorkflowInput{Artifact: "project", Frames: frames.Contiguous(1, 10), MaxActivityAttempts: 2})
+
+	s.True(s.env.IsWorkflowCompleted())
+
+	var appErr *temporal.ApplicationError
+	s.True(errors.As(s.env.GetWorkflowError(), &appErr))
+	s.False(appErr.NonRetryable())
+}
+
+func (s *WorkflowTestSuite) TestCancel() {
+	s.mockWorkspace(false)
+	s.env.OnActivity(blenderAct.RenderFramesActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, workingDir string, frameSpec frames.Spec, renderer string, slotTimeout time.Duration) (string, error) {
+			s.env.CancelWorkflow()
+			<-ctx.Done()
+			return "", ctx.Err()
+		}).Once()
+
+	s.env.ExecuteWorkflow(BlenderNodeWorkflow, BlenderNodeWorkflowInput{Artifact: "project", Frames: frames.Contiguous(1, 10)})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(temporal.IsCanceledError(s.env.GetWorkflowError()))
+}
+
+func (s *WorkflowTestSuite) TestSessionCreationFailure() {
+	s.env.OnActivity(sessionCreationActivity, mock.Anything, mock.Anything).Return(errors.New("no node available"))
+
+	s.env.ExecuteWorkflow(BlenderNodeWorkflow, BlenderNodeWorkflowInput{Artifact: "project", Frames: frames.Contiguous(1, 10)})
+
+	s.True(s.env.IsWorkflowCompleted())
+
+	var appErr *temporal.ApplicationError
+	s.True(errors.As(s.env.GetWorkflowError(), &appErr))
+	s.Contains(appErr.Error(), "no node available")
+
+	var details FailureDetails
+	s.NoError(appErr.Details(&details))
+	s.Equal(1, details.Attempt)
+}
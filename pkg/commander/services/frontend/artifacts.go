@@ -0,0 +1,147 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/flowshot-io/commander-client-go/commanderservice/v1"
+	"github.com/flowshot-io/commander/pkg/commander/services/blenderfarm"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// ListOutputArtifactsMethod lists a page of the output artifacts of the finished batches of a job,
+	// in frame order. The request is a GetBlenderFarmWorkflowRequest naming the job, paged with
+	// MetadataPageSize and MetadataPageToken, and the artifacts are returned as a structpb.ListValue
+	// of strings. The response header carries MetadataOutputArtifactCount and, unless the page is
+	// the last one, MetadataNextPageToken.
+	ListOutputArtifactsMethod = "/commander.v1.OutputArtifactService/ListOutputArtifacts"
+
+	// defaultArtifactPageSize is used when a ListOutputArtifacts request does not set MetadataPageSize.
+	defaultArtifactPageSize = 100
+)
+
+// outputArtifactServiceDesc describes the output artifact service by hand, as the commanderservice protos
+// are shared with existing clients and have no messages for it.
+var outputArtifactServiceDesc = grpc.ServiceDesc{
+	ServiceName: "commander.v1.OutputArtifactService",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListOutputArtifacts",
+			Handler:    listOutputArtifactsHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+func listOutputArtifactsHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(commanderservice.GetBlenderFarmWorkflowRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+
+	if interceptor == nil {
+		return srv.(*server).ListOutputArtifacts(ctx, req)
+	}
+
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: ListOutputArtifactsMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*server).ListOutputArtifacts(ctx, req.(*commanderservice.GetBlenderFarmWorkflowRequest))
+	}
+
+	return interceptor(ctx, req, info, handler)
+}
+
+// ListOutputArtifacts returns a page of the output artifacts of the finished batches of a job.
+func (s *server) ListOutputArtifacts(ctx context.Context, req *commanderservice.GetBlenderFarmWorkflowRequest) (*structpb.ListValue, error) {
+	offset, limit, err := artifactPageFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	page, err := s.queryBatches(ctx, req.Id, blenderfarm.BatchDone, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := &structpb.ListValue{}
+	for _, batch := range page.Batches {
+		// Batches restored from state carried without their artifact have none to list.
+		if batch.Artifact == "" {
+			continue
+		}
+
+		artifacts.Values = append(artifacts.Values, structpb.NewStringValue(batch.Artifact))
+	}
+
+	header := metadata.Pairs(MetadataOutputArtifactCount, strconv.Itoa(page.Total))
+	if next := offset + len(page.Batches); len(page.Batches) > 0 && next < page.Total {
+		header.Append(MetadataNextPageToken, strconv.Itoa(next))
+	}
+
+	if err := grpc.SetHeader(ctx, header); err != nil {
+		return nil, err
+	}
+
+	return artifacts, nil
+}
+
+// artifactPageFromContext returns the offset and size of the page of output artifacts set on the
+// incoming request metadata. The page token of output artifacts is the offset of the page.
+func artifactPageFromContext(ctx context.Context) (int, int, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	limit := defaultArtifactPageSize
+	if v := lastValue(md, MetadataPageSize); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size <= 0 {
+			return 0, 0, fmt.Errorf("invalid %s: %q", MetadataPageSize, v)
+		}
+
+		limit = size
+	}
+
+	if limit > blenderfarm.MaxBatchPageSize {
+		limit = blenderfarm.MaxBatchPageSize
+	}
+
+	offset := 0
+	if v := lastValue(md, MetadataPageToken); v != "" {
+		token, err := strconv.Atoi(v)
+		if err != nil || token < 0 {
+			return 0, 0, fmt.Errorf("invalid %s", MetadataPageToken)
+		}
+
+		offset = token
+	}
+
+	return offset, limit, nil
+}
+
+// ListOutputArtifacts returns a page of at most pageSize output artifacts of job from a frontend reachable
+// through conn, starting at pageToken, and the token of the next page, which is empty on the last page.
+func ListOutputArtifacts(ctx context.Context, conn grpc.ClientConnInterface, job string, pageSize int, pageToken string) ([]string, string, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, MetadataPageSize, strconv.Itoa(pageSize))
+	if pageToken != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, MetadataPageToken, pageToken)
+	}
+
+	var header metadata.MD
+	resp := new(structpb.ListValue)
+	if err := conn.Invoke(ctx, ListOutputArtifactsMethod, &commanderservice.GetBlenderFarmWorkflowRequest{Id: job}, resp, grpc.Header(&header)); err != nil {
+		return nil, "", err
+	}
+
+	artifacts := make([]string, 0, len(resp.Values))
+	for _, value := range resp.Values {
+		artifacts = append(artifacts, value.GetStringValue())
+	}
+
+	return artifacts, lastValue(header, MetadataNextPageToken), nil
+}
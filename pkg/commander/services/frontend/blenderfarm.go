@@ -2,44 +2,200 @@ package frontend
 
 import (
 	"context"
+	"encoding/base64"
+	"strconv"
 
 	"github.com/flowshot-io/commander-client-go/commanderservice/v1"
+	"github.com/flowshot-io/commander/pkg/commander/auth"
+	"github.com/flowshot-io/commander/pkg/commander/frames"
+	"github.com/flowshot-io/commander/pkg/commander/renderlog"
 	"github.com/flowshot-io/commander/pkg/commander/services/blenderfarm"
+	"github.com/flowshot-io/commander/pkg/commander/services/blendernode"
+	"go.temporal.io/api/workflowservice/v1"
 	"go.temporal.io/sdk/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+// maxProgressBatches bounds the running batches GetBlenderFarmWorkflow queries for their render progress.
+const maxProgressBatches = 50
+
 func (s *server) GetBlenderFarmWorkflow(ctx context.Context, req *commanderservice.GetBlenderFarmWorkflowRequest) (*commanderservice.GetBlenderFarmWorkflowResponse, error) {
-	response, err := s.temporal.QueryWorkflow(ctx, req.Id, "", blenderfarm.Query)
+	summary, err := s.querySummary(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the first running batches are asked for their progress, so a job with many batches
+	// running does not turn every call into as many queries.
+	running, err := s.queryBatches(ctx, req.Id, blenderfarm.BatchRunning, 0, maxProgressBatches)
 	if err != nil {
 		return nil, err
 	}
 
-	var res map[string]interface{}
-	if err := response.Get(&res); err != nil {
+	rendering := s.batchRenderProgress(ctx, running.Batches)
+
+	percentComplete := summary.PercentComplete()
+	if summary.FramesTotal > 0 {
+		percentComplete = float64(summary.FramesDone+rendering.FramesCompleted) / float64(summary.FramesTotal) * 100
+	}
+
+	header := metadata.Pairs(
+		MetadataFramesDone, strconv.Itoa(summary.FramesDone),
+		MetadataFramesRendered, strconv.Itoa(rendering.FramesCompleted),
+		MetadataFramesTotal, strconv.Itoa(summary.FramesTotal),
+		MetadataPercentComplete, strconv.FormatFloat(percentComplete, 'f', 2, 64),
+		MetadataPeakMemory, strconv.FormatInt(rendering.PeakMemory, 10),
+		MetadataOutputArtifactCount, strconv.Itoa(summary.BatchesDone),
+	)
+	if summary.Status == blenderfarm.BatchCanceled {
+		header.Append(MetadataCanceledReason, summary.CancelReason)
+	}
+
+	if err := grpc.SetHeader(ctx, header); err != nil {
 		return nil, err
 	}
 
 	return &commanderservice.GetBlenderFarmWorkflowResponse{
-		Status: &commanderservice.RenderStatus{
-			Id: req.Id,
-		},
+		Status: renderStatusFromSummary(req.Id, summary),
 	}, nil
 }
 
+// querySummary returns the Summary of a job.
+func (s *server) querySummary(ctx context.Context, id string) (blenderfarm.Summary, error) {
+	response, err := s.temporal.QueryWorkflow(ctx, id, "", blenderfarm.Query)
+	if err != nil {
+		return blenderfarm.Summary{}, err
+	}
+
+	var summary blenderfarm.Summary
+	if err := response.Get(&summary); err != nil {
+		return blenderfarm.Summary{}, err
+	}
+
+	return summary, nil
+}
+
+// queryBatches returns a page of the batches of a job with the given status, or of every batch when status is empty.
+func (s *server) queryBatches(ctx context.Context, id string, status blenderfarm.BatchStatus, offset int, limit int) (blenderfarm.BatchPage, error) {
+	response, err := s.temporal.QueryWorkflow(ctx, id, "", blenderfarm.BatchesQuery, status, offset, limit)
+	if err != nil {
+		return blenderfarm.BatchPage{}, err
+	}
+
+	var page blenderfarm.BatchPage
+	if err := response.Get(&page); err != nil {
+		return blenderfarm.BatchPage{}, err
+	}
+
+	return page, nil
+}
+
+// batchRenderProgress sums the render progress of the running batches of a job. Batches that finish
+// while they are queried are left out, their frames are already counted as done by the job.
+func (s *server) batchRenderProgress(ctx context.Context, running []blenderfarm.Batch) renderlog.Progress {
+	var total renderlog.Progress
+	for _, batch := range running {
+		if batch.WorkflowID == "" {
+			continue
+		}
+
+		response, err := s.temporal.QueryWorkflow(ctx, batch.WorkflowID, "", blendernode.Query)
+		if err != nil {
+			continue
+		}
+
+		var batchProgress renderlog.Progress
+		if err := response.Get(&batchProgress); err != nil {
+			continue
+		}
+
+		total.FramesCompleted += batchProgress.FramesCompleted
+		total.FramesTotal += batchProgress.FramesTotal
+		if batchProgress.PeakMemory > total.PeakMemory {
+			total.PeakMemory = batchProgress.PeakMemory
+		}
+	}
+
+	return total
+}
+
 func (s *server) ListBlenderFarmWorkflows(ctx context.Context, req *commanderservice.ListBlenderFarmWorkflowsRequest) (*commanderservice.ListBlenderFarmWorkflowsResponse, error) {
-	return &commanderservice.ListBlenderFarmWorkflowsResponse{}, nil
+	filter, err := listFilterFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	response, err := s.temporal.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+		PageSize:      filter.pageSize,
+		NextPageToken: filter.pageToken,
+		Query:         filter.query(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.NextPageToken) > 0 {
+		header := metadata.Pairs(MetadataNextPageToken, base64.RawURLEncoding.EncodeToString(response.NextPageToken))
+		if err := grpc.SetHeader(ctx, header); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]*commanderservice.RenderStatus, 0, len(response.Executions))
+	for _, execution := range response.Executions {
+		statuses = append(statuses, renderStatusFromExecution(execution))
+	}
+
+	return &commanderservice.ListBlenderFarmWorkflowsResponse{
+		Status: statuses,
+	}, nil
 }
 
 func (s *server) CreateBlenderFarmWorkflow(ctx context.Context, req *commanderservice.CreateBlenderFarmWorkflowRequest) (*commanderservice.CreateBlenderFarmWorkflowResponse, error) {
+	frameSpec, err := framesFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	rendererName, err := rendererFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	jobFrames := frameSpec
+	if len(jobFrames) == 0 {
+		jobFrames = frames.Contiguous(int(req.StartFrame), int(req.EndFrame))
+	}
+
+	if err := blenderfarm.ValidateFrames(jobFrames, int(req.BatchSize)); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
 	workflowOptions := client.StartWorkflowOptions{
 		TaskQueue: blenderfarm.Queue,
+		Memo: map[string]interface{}{
+			memoArtifact: req.File,
+		},
+	}
+	if principal, ok := auth.FromContext(ctx); ok {
+		workflowOptions.Memo[memoPrincipal] = principal
 	}
 
 	we, err := s.temporal.ExecuteWorkflow(ctx, workflowOptions, blenderfarm.BlenderFarmWorkflow, blenderfarm.BlenderFarmWorkflowInput{
-		Artifact:   req.File,
-		StartFrame: int(req.StartFrame),
-		EndFrame:   int(req.EndFrame),
-		BatchSize:  int(req.BatchSize),
+		Artifact:            req.File,
+		Frames:              frameSpec,
+		StartFrame:          int(req.StartFrame),
+		EndFrame:            int(req.EndFrame),
+		BatchSize:           int(req.BatchSize),
+		MaxParallelBatches:  s.maxParallelBatches,
+		HistoryThreshold:    s.historyThreshold,
+		CacheRoutingTimeout: s.cacheRoutingTimeout,
+		MaxBatchAttempts:    s.maxBatchAttempts,
+		MaxActivityAttempts: s.maxActivityAttempts,
+		Renderer:            rendererName,
 	})
 	if err != nil {
 		return nil, err
@@ -51,7 +207,13 @@ func (s *server) CreateBlenderFarmWorkflow(ctx context.Context, req *commanderse
 }
 
 func (s *server) CancelBlenderFarmWorkflow(ctx context.Context, req *commanderservice.CancelBlenderFarmWorkflowRequest) (*commanderservice.CancelBlenderFarmWorkflowResponse, error) {
-	err := s.temporal.CancelWorkflow(ctx, req.Id, "")
+	// The reason is signalled first so the workflow can record it once it sees the cancellation.
+	err := s.temporal.SignalWorkflow(ctx, req.Id, "", blenderfarm.CancelSignal, cancelReasonFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.temporal.CancelWorkflow(ctx, req.Id, "")
 	if err != nil {
 		return nil, err
 	}
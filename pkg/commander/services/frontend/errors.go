@@ -0,0 +1,33 @@
+package frontend
+
+import (
+	"context"
+
+	"go.temporal.io/api/serviceerror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// errorStatus maps an error returned by a handler to the status sent to clients, so
+// Temporal errors such as a missing workflow keep their code instead of becoming Unknown.
+func errorStatus(err error) *status.Status {
+	st := serviceerror.ToStatus(err)
+	return status.New(st.Code(), st.Message())
+}
+
+func errorUnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, errorStatus(err).Err()
+	}
+
+	return resp, nil
+}
+
+func errorStreamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := handler(srv, ss); err != nil {
+		return errorStatus(err).Err()
+	}
+
+	return nil
+}
@@ -0,0 +1,411 @@
+package frontend
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/flowshot-io/commander-client-go/commanderservice/v1"
+	"github.com/flowshot-io/commander/pkg/commander/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// DefaultGatewayPort is used when the gateway listener does not set a port or Unix socket.
+	DefaultGatewayPort = 8080
+	// OpenAPIPath serves the OpenAPI document of the gateway.
+	OpenAPIPath = "/openapi.json"
+
+	gatewayReadHeaderTimeout = 10 * time.Second
+	gatewayShutdownTimeout   = 30 * time.Second
+
+	// defaultGatewayMaxBodySize matches the default gRPC limit on received messages.
+	defaultGatewayMaxBodySize = 4 << 20
+	metadataPrefix            = "x-commander-"
+)
+
+type (
+	// gatewayRoute maps an HTTP method and path to a method of the frontend.
+	gatewayRoute struct {
+		method string
+		path   string
+		rpc    string
+		// fullMethod is the gRPC method of routes to a method outside CommanderService.
+		fullMethod string
+		summary    string
+		// body is true when the request message is read from the JSON body.
+		body bool
+		// params are query parameters passed to the handler as request metadata.
+		params []gatewayParam
+		// headers are the response metadata returned as HTTP headers.
+		headers  []gatewayParam
+		request  func() proto.Message
+		response proto.Message
+		call     func(ctx context.Context, s *server, req proto.Message) (proto.Message, error)
+	}
+
+	gatewayParam struct {
+		name        string
+		metadata    string
+		description string
+	}
+
+	gateway struct {
+		impl        *server
+		authOptions *auth.Options
+		maxBodySize int64
+	}
+
+	// gatewayStream collects the metadata a handler sets so it can be returned as HTTP headers.
+	gatewayStream struct {
+		method string
+		header metadata.MD
+	}
+)
+
+var commanderService = commanderservice.File_commander_api_commanderservice_v1_service_proto.Services().ByName("CommanderService")
+
+var gatewayRoutes = []gatewayRoute{
+	{
+		method:  http.MethodPost,
+		path:    "/v1/workflows",
+		rpc:     "CreateBlenderFarmWorkflow",
+		summary: "Create a blender farm workflow",
+		body:    true,
+		params: []gatewayParam{
+			{name: "frames", metadata: MetadataFrames, description: "Frames to render, such as 1-10,20,30-40x2. Overrides the start and end frame."},
+			{name: "renderer", metadata: MetadataRenderer, description: "Renderer backend to use instead of the node default."},
+		},
+		request:  func() proto.Message { return &commanderservice.CreateBlenderFarmWorkflowRequest{} },
+		response: &commanderservice.CreateBlenderFarmWorkflowResponse{},
+		call: func(ctx context.Context, s *server, req proto.Message) (proto.Message, error) {
+			return s.CreateBlenderFarmWorkflow(ctx, req.(*commanderservice.CreateBlenderFarmWorkflowRequest))
+		},
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/v1/workflows",
+		rpc:     "ListBlenderFarmWorkflows",
+		summary: "List blender farm workflows",
+		params: []gatewayParam{
+			{name: "status", metadata: MetadataStatus, description: "Comma separated statuses to list: running, completed, failed or cancelled."},
+			{name: "started_after", metadata: MetadataStartedAfter, description: "Only list workflows started at or after this RFC 3339 time."},
+			{name: "started_before", metadata: MetadataStartedBefore, description: "Only list workflows started before this RFC 3339 time."},
+			{name: "page_size", metadata: MetadataPageSize, description: "Number of workflows per page."},
+			{name: "page_token", metadata: MetadataPageToken, description: "Token of the page to list, from the next page token header."},
+		},
+		headers: []gatewayParam{
+			{metadata: MetadataNextPageToken, description: "Token of the next page, absent on the last page."},
+		},
+		request:  func() proto.Message { return &commanderservice.ListBlenderFarmWorkflowsRequest{} },
+		response: &commanderservice.ListBlenderFarmWorkflowsResponse{},
+		call: func(ctx context.Context, s *server, req proto.Message) (proto.Message, error) {
+			return s.ListBlenderFarmWorkflows(ctx, req.(*commanderservice.ListBlenderFarmWorkflowsRequest))
+		},
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/v1/workflows/{id}",
+		rpc:     "GetBlenderFarmWorkflow",
+		summary: "Get a blender farm workflow",
+		headers: []gatewayParam{
+			{metadata: MetadataFramesDone, description: "Frames of finished batches."},
+			{metadata: MetadataFramesRendered, description: "Frames rendered so far by running batches."},
+			{metadata: MetadataFramesTotal, description: "Frames the workflow renders."},
+			{metadata: MetadataPercentComplete, description: "Percentage of the frames rendered."},
+			{metadata: MetadataPeakMemory, description: "Peak memory in bytes used by a running batch."},
+			{metadata: MetadataOutputArtifactCount, description: "Finished batches, whose output artifacts are listed by ListOutputArtifacts."},
+			{metadata: MetadataCanceledReason, description: "Reason the workflow was canceled."},
+		},
+		request:  func() proto.Message { return &commanderservice.GetBlenderFarmWorkflowRequest{} },
+		response: &commanderservice.GetBlenderFarmWorkflowResponse{},
+		call: func(ctx context.Context, s *server, req proto.Message) (proto.Message, error) {
+			return s.GetBlenderFarmWorkflow(ctx, req.(*commanderservice.GetBlenderFarmWorkflowRequest))
+		},
+	},
+	{
+		method:     http.MethodGet,
+		path:       "/v1/workflows/{id}/artifacts",
+		rpc:        "ListOutputArtifacts",
+		fullMethod: ListOutputArtifactsMethod,
+		summary:    "List the output artifacts of the finished batches of a blender farm workflow",
+		params: []gatewayParam{
+			{name: "page_size", metadata: MetadataPageSize, description: "Number of output artifacts per page."},
+			{name: "page_token", metadata: MetadataPageToken, description: "Token of the page to list, from the next page token header."},
+		},
+		headers: []gatewayParam{
+			{metadata: MetadataOutputArtifactCount, description: "Finished batches of the workflow."},
+			{metadata: MetadataNextPageToken, description: "Token of the next page, absent on the last page."},
+		},
+		request:  func() proto.Message { return &commanderservice.GetBlenderFarmWorkflowRequest{} },
+		response: &structpb.ListValue{},
+		call: func(ctx context.Context, s *server, req proto.Message) (proto.Message, error) {
+			return s.ListOutputArtifacts(ctx, req.(*commanderservice.GetBlenderFarmWorkflowRequest))
+		},
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/v1/workflows/{id}/cancel",
+		rpc:     "CancelBlenderFarmWorkflow",
+		summary: "Cancel a blender farm workflow",
+		params: []gatewayParam{
+			{name: "reason", metadata: MetadataCancelReason, description: "Reason recorded on the canceled workflow."},
+		},
+		request:  func() proto.Message { return &commanderservice.CancelBlenderFarmWorkflowRequest{} },
+		response: &commanderservice.CancelBlenderFarmWorkflowResponse{},
+		call: func(ctx context.Context, s *server, req proto.Message) (proto.Message, error) {
+			return s.CancelBlenderFarmWorkflow(ctx, req.(*commanderservice.CancelBlenderFarmWorkflowRequest))
+		},
+	},
+}
+
+// httpStatusCodes maps gRPC codes to HTTP status codes the way grpc-gateway does.
+var httpStatusCodes = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+// ServeHTTP serves the routes of the gateway and its OpenAPI document.
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == OpenAPIPath && r.Method == http.MethodGet {
+		doc, err := OpenAPI()
+		if err != nil {
+			writeError(w, status.New(codes.Internal, err.Error()))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(doc)
+		return
+	}
+
+	var allowed []string
+	for _, route := range gatewayRoutes {
+		vars, ok := matchPath(route.path, r.URL.Path)
+		if !ok {
+			continue
+		}
+
+		if route.method == r.Method {
+			g.serve(w, r, route, vars)
+			return
+		}
+
+		allowed = append(allowed, route.method)
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeErrorCode(w, http.StatusMethodNotAllowed, status.Newf(codes.Unimplemented, "method %s is not allowed on %s", r.Method, r.URL.Path))
+		return
+	}
+
+	writeError(w, status.Newf(codes.NotFound, "no route for %s", r.URL.Path))
+}
+
+func (g *gateway) serve(w http.ResponseWriter, r *http.Request, route gatewayRoute, vars map[string]string) {
+	req := route.request()
+	if route.body {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, g.maxBodySize))
+		if err != nil {
+			writeError(w, status.Newf(codes.InvalidArgument, "unable to read request body: %v", err))
+			return
+		}
+
+		if err := protojson.Unmarshal(body, req); err != nil {
+			writeError(w, status.Newf(codes.InvalidArgument, "invalid request body: %v", err))
+			return
+		}
+	}
+
+	for name, value := range vars {
+		field := req.ProtoReflect().Descriptor().Fields().ByName(protoreflect.Name(name))
+		if field == nil || field.Kind() != protoreflect.StringKind {
+			writeError(w, status.Newf(codes.Internal, "route %s has no string field %s", route.path, name))
+			return
+		}
+
+		req.ProtoReflect().Set(field, protoreflect.ValueOfString(value))
+	}
+
+	stream := &gatewayStream{method: route.grpcMethod(), header: metadata.MD{}}
+	ctx := metadata.NewIncomingContext(r.Context(), requestMetadata(r, route))
+	ctx = grpc.NewContextWithServerTransportStream(ctx, stream)
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return route.call(ctx, g.impl, req.(proto.Message))
+	}
+
+	var (
+		resp interface{}
+		err  error
+	)
+	if g.authOptions != nil {
+		resp, err = auth.UnaryServerInterceptor(*g.authOptions)(ctx, req, &grpc.UnaryServerInfo{Server: g.impl, FullMethod: stream.method}, handler)
+	} else {
+		resp, err = handler(ctx, req)
+	}
+	if err != nil {
+		writeError(w, errorStatus(err))
+		return
+	}
+
+	body, err := protojson.Marshal(resp.(proto.Message))
+	if err != nil {
+		writeError(w, status.New(codes.Internal, err.Error()))
+		return
+	}
+
+	for key, values := range stream.header {
+		w.Header()[textproto.CanonicalMIMEHeaderKey(key)] = values
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+// requestMetadata passes the credentials and commander headers of an HTTP request, and
+// the query parameters of its route, to the handler as gRPC metadata.
+func requestMetadata(r *http.Request, route gatewayRoute) metadata.MD {
+	md := metadata.MD{}
+	for key, values := range r.Header {
+		key = strings.ToLower(key)
+		if key == auth.MetadataAuthorization || key == auth.MetadataAPIKey || strings.HasPrefix(key, metadataPrefix) {
+			md.Append(key, values...)
+		}
+	}
+
+	query := r.URL.Query()
+	for _, param := range route.params {
+		if values, ok := query[param.name]; ok {
+			md.Set(param.metadata, values...)
+		}
+	}
+
+	return md
+}
+
+// matchPath matches a path against a route path, returning the values of its {name} segments.
+func matchPath(pattern, path string) (map[string]string, bool) {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternSegments) != len(pathSegments) {
+		return nil, false
+	}
+
+	vars := map[string]string{}
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if pathSegments[i] == "" {
+				return nil, false
+			}
+
+			vars[strings.Trim(segment, "{}")] = pathSegments[i]
+			continue
+		}
+
+		if segment != pathSegments[i] {
+			return nil, false
+		}
+	}
+
+	return vars, true
+}
+
+// writeError writes st as a JSON google.rpc.Status with the HTTP status code matching its code.
+func writeError(w http.ResponseWriter, st *status.Status) {
+	code, ok := httpStatusCodes[st.Code()]
+	if !ok {
+		code = http.StatusInternalServerError
+	}
+
+	writeErrorCode(w, code, st)
+}
+
+func writeErrorCode(w http.ResponseWriter, code int, st *status.Status) {
+	body, err := protojson.Marshal(st.Proto())
+	if err != nil {
+		http.Error(w, st.Message(), code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+}
+
+// grpcMethod returns the full gRPC method name of the route.
+func (route gatewayRoute) grpcMethod() string {
+	if route.fullMethod != "" {
+		return route.fullMethod
+	}
+
+	return fmt.Sprintf("/%s/%s", commanderService.FullName(), route.rpc)
+}
+
+// gatewayTLSConfig returns cfg offering HTTP/1.1 as well as HTTP/2, since
+// the config gRPC is served with only offers HTTP/2.
+func gatewayTLSConfig(cfg *tls.Config) *tls.Config {
+	protos := []string{"h2", "http/1.1"}
+
+	cfg = cfg.Clone()
+	cfg.NextProtos = protos
+	if getConfig := cfg.GetConfigForClient; getConfig != nil {
+		cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			c, err := getConfig(hello)
+			if c == nil || err != nil {
+				return c, err
+			}
+
+			c = c.Clone()
+			c.NextProtos = protos
+			return c, nil
+		}
+	}
+
+	return cfg
+}
+
+func (s *gatewayStream) Method() string {
+	return s.method
+}
+
+func (s *gatewayStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *gatewayStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *gatewayStream) SetTrailer(md metadata.MD) error {
+	return nil
+}
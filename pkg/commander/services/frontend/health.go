@@ -0,0 +1,72 @@
+package frontend
+
+import (
+	"context"
+	"time"
+
+	"go.temporal.io/api/workflowservice/v1"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// healthCheckInterval is how often the frontend checks that Temporal is reachable.
+	healthCheckInterval = 10 * time.Second
+	healthCheckTimeout  = 5 * time.Second
+)
+
+// healthMethods are served without authentication so load balancers can probe the frontend.
+var healthMethods = []string{
+	"/grpc.health.v1.Health/Check",
+	"/grpc.health.v1.Health/Watch",
+}
+
+// watchHealth reports the frontend as serving while the Temporal namespace is reachable, until stop is closed.
+func (s *Service) watchHealth(stop <-chan struct{}) {
+	defer close(s.healthDone)
+
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	serving := false
+	for {
+		err := s.checkTemporal()
+		if (err == nil) != serving {
+			serving = err == nil
+			if serving {
+				s.Logger.Info("Temporal is reachable, frontend is serving")
+			} else {
+				s.Logger.Warn("Temporal is unreachable, frontend is not serving", map[string]interface{}{"Error": err.Error()})
+			}
+		}
+
+		s.setServingStatus(serving)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkTemporal returns an error unless the namespace can be described.
+func (s *Service) checkTemporal() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	_, err := s.temporal.WorkflowService().DescribeNamespace(ctx, &workflowservice.DescribeNamespaceRequest{Namespace: s.namespace})
+	return err
+}
+
+// setServingStatus sets the status of the server as a whole and of every service it registers.
+func (s *Service) setServingStatus(serving bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+
+	s.Health.SetServingStatus("", status)
+	for name := range s.Server.GetServiceInfo() {
+		s.Health.SetServingStatus(name, status)
+	}
+}
@@ -0,0 +1,129 @@
+package frontend
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flowshot-io/commander/pkg/commander/services/blenderfarm"
+	enumspb "go.temporal.io/api/enums/v1"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+
+	memoArtifact = "Artifact"
+	// memoPrincipal records the auth.Principal that created a job.
+	memoPrincipal = "Principal"
+)
+
+var listStatuses = map[string]enumspb.WorkflowExecutionStatus{
+	"running":   enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING,
+	"completed": enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED,
+	"failed":    enumspb.WORKFLOW_EXECUTION_STATUS_FAILED,
+	"cancelled": enumspb.WORKFLOW_EXECUTION_STATUS_CANCELED,
+}
+
+type listFilter struct {
+	statuses      []enumspb.WorkflowExecutionStatus
+	startedAfter  time.Time
+	startedBefore time.Time
+	pageSize      int32
+	pageToken     []byte
+}
+
+// listFilterFromContext builds a listFilter from the incoming request metadata.
+func listFilterFromContext(ctx context.Context) (*listFilter, error) {
+	f := &listFilter{pageSize: defaultPageSize}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return f, nil
+	}
+
+	for _, value := range md.Get(MetadataStatus) {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+
+			status, ok := listStatuses[name]
+			if !ok {
+				return nil, fmt.Errorf("invalid status %q", name)
+			}
+
+			f.statuses = append(f.statuses, status)
+		}
+	}
+
+	if v := lastValue(md, MetadataStartedAfter); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", MetadataStartedAfter, err)
+		}
+		f.startedAfter = t
+	}
+
+	if v := lastValue(md, MetadataStartedBefore); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", MetadataStartedBefore, err)
+		}
+		f.startedBefore = t
+	}
+
+	if v := lastValue(md, MetadataPageSize); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid %s: %q", MetadataPageSize, v)
+		}
+		if size > maxPageSize {
+			size = maxPageSize
+		}
+		f.pageSize = int32(size)
+	}
+
+	if v := lastValue(md, MetadataPageToken); v != "" {
+		token, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s", MetadataPageToken)
+		}
+		f.pageToken = token
+	}
+
+	return f, nil
+}
+
+// query returns the Temporal visibility query for the filter.
+func (f *listFilter) query() string {
+	clauses := []string{
+		fmt.Sprintf("WorkflowType = '%s'", blenderfarm.WorkflowType),
+		fmt.Sprintf("TaskQueue = '%s'", blenderfarm.Queue),
+		// Runs that continued as new are listed once, by their latest run.
+		fmt.Sprintf("ExecutionStatus != '%s'", enumspb.WORKFLOW_EXECUTION_STATUS_CONTINUED_AS_NEW.String()),
+	}
+
+	if len(f.statuses) > 0 {
+		var statuses []string
+		for _, status := range f.statuses {
+			statuses = append(statuses, fmt.Sprintf("ExecutionStatus = '%s'", status.String()))
+		}
+		clauses = append(clauses, "("+strings.Join(statuses, " OR ")+")")
+	}
+
+	if !f.startedAfter.IsZero() {
+		clauses = append(clauses, fmt.Sprintf("StartTime >= '%s'", f.startedAfter.UTC().Format(time.RFC3339Nano)))
+	}
+
+	if !f.startedBefore.IsZero() {
+		clauses = append(clauses, fmt.Sprintf("StartTime < '%s'", f.startedBefore.UTC().Format(time.RFC3339Nano)))
+	}
+
+	return strings.Join(clauses, " AND ")
+}
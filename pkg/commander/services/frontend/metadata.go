@@ -0,0 +1,127 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/flowshot-io/commander/pkg/commander/frames"
+	"github.com/flowshot-io/commander/pkg/commander/renderer"
+	"google.golang.org/grpc/metadata"
+)
+
+// The commanderservice messages only carry a subset of what the workflows
+// track, so list filters and job progress are exchanged as gRPC metadata.
+const (
+	// MetadataFrames is a frame spec such as "1-10,15,20-100x5" that overrides the
+	// start and end frame of a CreateBlenderFarmWorkflow request.
+	MetadataFrames = "x-commander-frames"
+
+	// MetadataRenderer overrides the default renderer of the nodes for a CreateBlenderFarmWorkflow request.
+	MetadataRenderer = "x-commander-renderer"
+
+	// MetadataBatch is the index of the batch whose render log is streamed by StreamRenderLogMethod.
+	MetadataBatch = "x-commander-batch"
+
+	// MetadataCancelReason is recorded as the reason of a CancelBlenderFarmWorkflow request.
+	MetadataCancelReason = "x-commander-cancel-reason"
+
+	// MetadataStatus is a comma separated list of running, completed, failed or cancelled.
+	MetadataStatus = "x-commander-status"
+	// MetadataStartedAfter only returns jobs started at or after this RFC 3339 time.
+	MetadataStartedAfter = "x-commander-started-after"
+	// MetadataStartedBefore only returns jobs started before this RFC 3339 time.
+	MetadataStartedBefore = "x-commander-started-before"
+	// MetadataPageSize limits the number of jobs returned.
+	MetadataPageSize = "x-commander-page-size"
+	// MetadataPageToken resumes a listing from a previous next page token.
+	MetadataPageToken = "x-commander-page-token"
+	// MetadataNextPageToken is set on the response header when more jobs are available.
+	MetadataNextPageToken = "x-commander-next-page-token"
+
+	// MetadataFramesDone is set on the GetBlenderFarmWorkflow response header.
+	MetadataFramesDone = "x-commander-frames-done"
+	// MetadataFramesRendered is set on the GetBlenderFarmWorkflow response header to the frames
+	// already saved by the first 50 batches that are still running.
+	MetadataFramesRendered = "x-commander-frames-rendered"
+	// MetadataPeakMemory is set on the GetBlenderFarmWorkflow response header to the highest peak
+	// memory in bytes reported by one of the first 50 running batches.
+	MetadataPeakMemory = "x-commander-peak-memory"
+	// MetadataFramesTotal is set on the GetBlenderFarmWorkflow response header.
+	MetadataFramesTotal = "x-commander-frames-total"
+	// MetadataPercentComplete is set on the GetBlenderFarmWorkflow response header.
+	MetadataPercentComplete = "x-commander-percent-complete"
+	// MetadataOutputArtifactCount is set on the GetBlenderFarmWorkflow and ListOutputArtifactsMethod
+	// response headers to the number of finished batches, whose output artifacts are listed by
+	// ListOutputArtifactsMethod.
+	MetadataOutputArtifactCount = "x-commander-output-artifact-count"
+	// MetadataCanceledReason is set on the GetBlenderFarmWorkflow response header of a canceled job.
+	MetadataCanceledReason = "x-commander-canceled-reason"
+)
+
+// framesFromContext returns the frame spec set on the incoming request metadata, if any.
+func framesFromContext(ctx context.Context) (frames.Spec, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+
+	v := lastValue(md, MetadataFrames)
+	if v == "" {
+		return nil, nil
+	}
+
+	return frames.Parse(v)
+}
+
+// rendererFromContext returns the renderer set on the incoming request metadata, if any.
+func rendererFromContext(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", nil
+	}
+
+	v := lastValue(md, MetadataRenderer)
+	if v != "" && !renderer.Valid(v) {
+		return "", fmt.Errorf("unknown renderer: %q", v)
+	}
+
+	return v, nil
+}
+
+// batchFromContext returns the batch index set on the incoming request metadata.
+func batchFromContext(ctx context.Context) (int, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	v := lastValue(md, MetadataBatch)
+	if v == "" {
+		return 0, fmt.Errorf("%s is required", MetadataBatch)
+	}
+
+	index, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", MetadataBatch, err)
+	}
+
+	return index, nil
+}
+
+// cancelReasonFromContext returns the cancellation reason set on the incoming request metadata, if any.
+func cancelReasonFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	return lastValue(md, MetadataCancelReason)
+}
+
+func lastValue(md metadata.MD, key string) string {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return strings.TrimSpace(values[len(values)-1])
+}
@@ -0,0 +1,198 @@
+package frontend
+
+import (
+	"encoding/json"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	openAPIVersion  = "3.0.3"
+	errorSchemaName = "Status"
+	schemaRefPrefix = "#/components/schemas/"
+	jsonContentType = "application/json"
+)
+
+type object = map[string]interface{}
+
+// OpenAPI returns the OpenAPI document of the HTTP/JSON gateway, generated from its
+// routes and the CommanderService messages they exchange.
+func OpenAPI() ([]byte, error) {
+	schemas := object{
+		errorSchemaName: object{
+			"type":        "object",
+			"description": "A google.rpc.Status describing why the call failed.",
+			"properties": object{
+				"code":    object{"type": "integer", "format": "int32", "description": "The gRPC status code."},
+				"message": object{"type": "string"},
+				"details": object{"type": "array", "items": object{"type": "object"}},
+			},
+		},
+	}
+
+	paths := object{}
+	for _, route := range gatewayRoutes {
+		item, ok := paths[route.path].(object)
+		if !ok {
+			item = object{}
+			paths[route.path] = item
+		}
+
+		item[strings.ToLower(route.method)] = openAPIOperation(route, schemas)
+	}
+
+	doc := object{
+		"openapi": openAPIVersion,
+		"info": object{
+			"title":   "Commander API",
+			"version": "v1",
+			"description": "HTTP/JSON gateway to " + string(commanderService.FullName()) +
+				". Every x-commander-* request header of the gRPC API is also accepted as an HTTP header.",
+		},
+		"paths": paths,
+		"components": object{
+			"schemas": schemas,
+			"securitySchemes": object{
+				"bearerAuth": object{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKey":     object{"type": "apiKey", "in": "header", "name": "X-Api-Key"},
+			},
+		},
+		"security": []object{{"bearerAuth": []string{}}, {"apiKey": []string{}}},
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func openAPIOperation(route gatewayRoute, schemas object) object {
+	var parameters []object
+	for _, segment := range strings.Split(route.path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			parameters = append(parameters, object{
+				"name":     strings.Trim(segment, "{}"),
+				"in":       "path",
+				"required": true,
+				"schema":   object{"type": "string"},
+			})
+		}
+	}
+
+	for _, param := range route.params {
+		parameters = append(parameters, object{
+			"name":        param.name,
+			"in":          "query",
+			"description": param.description,
+			"schema":      object{"type": "string"},
+		})
+	}
+
+	headers := object{}
+	for _, header := range route.headers {
+		headers[header.metadata] = object{
+			"description": header.description,
+			"schema":      object{"type": "string"},
+		}
+	}
+
+	operation := object{
+		"operationId": route.rpc,
+		"summary":     route.summary,
+		"responses": object{
+			"200": object{
+				"description": "OK",
+				"headers":     headers,
+				"content":     jsonContent(messageSchema(route.response.ProtoReflect().Descriptor(), schemas)),
+			},
+			"default": object{
+				"description": "The call failed. The HTTP status code follows the gRPC status code.",
+				"content":     jsonContent(schemaRef(errorSchemaName)),
+			},
+		},
+	}
+
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	if route.body {
+		operation["requestBody"] = object{
+			"required": true,
+			"content":  jsonContent(messageSchema(route.request().ProtoReflect().Descriptor(), schemas)),
+		}
+	}
+
+	return operation
+}
+
+// messageSchema adds the schema of md, and of the messages it refers to, to schemas and returns a reference to it.
+func messageSchema(md protoreflect.MessageDescriptor, schemas object) object {
+	// protojson encodes a ListValue as a bare array, only lists of strings are returned.
+	if md.FullName() == "google.protobuf.ListValue" {
+		return object{"type": "array", "items": object{"type": "string"}}
+	}
+
+	name := string(md.Name())
+	if _, ok := schemas[name]; ok {
+		return schemaRef(name)
+	}
+
+	properties := object{}
+	schema := object{"type": "object", "properties": properties}
+	schemas[name] = schema
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+
+		property := fieldSchema(field, schemas)
+		if field.IsList() {
+			property = object{"type": "array", "items": property}
+		}
+
+		properties[field.JSONName()] = property
+	}
+
+	return schemaRef(name)
+}
+
+// fieldSchema returns the schema of a single value of field, as protojson encodes it.
+func fieldSchema(field protoreflect.FieldDescriptor, schemas object) object {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return object{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return object{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return object{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// protojson encodes 64 bit integers as strings.
+		return object{"type": "string", "format": "int64"}
+	case protoreflect.FloatKind:
+		return object{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return object{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return object{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		names := make([]string, 0, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+
+		return object{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageSchema(field.Message(), schemas)
+	default:
+		return object{"type": "string"}
+	}
+}
+
+func schemaRef(name string) object {
+	return object{"$ref": schemaRefPrefix + name}
+}
+
+func jsonContent(schema object) object {
+	return object{jsonContentType: object{"schema": schema}}
+}
@@ -0,0 +1,160 @@
+package frontend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/flowshot-io/commander-client-go/commanderservice/v1"
+	"github.com/flowshot-io/commander/pkg/commander/renderlog"
+	"github.com/flowshot-io/commander/pkg/commander/services/blenderfarm"
+	"github.com/flowshot-io/commander/pkg/commander/services/blendernode"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	// StreamRenderLogMethod streams the render log of a batch of a job. The request is a
+	// GetBlenderFarmWorkflowRequest naming the job, with the batch index set as MetadataBatch,
+	// and the log is streamed as wrapperspb.BytesValue chunks.
+	StreamRenderLogMethod = "/commander.v1.RenderLogService/StreamRenderLog"
+
+	// renderLogChunkSize is the size of the chunks the render log is streamed in.
+	renderLogChunkSize = 32 << 10
+)
+
+// renderLogServiceDesc describes the render log service by hand, as the commanderservice protos are
+// shared with existing clients and have no messages for it.
+var renderLogServiceDesc = grpc.ServiceDesc{
+	ServiceName: "commander.v1.RenderLogService",
+	HandlerType: (*interface{})(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamRenderLog",
+			Handler:       streamRenderLogHandler,
+			ServerStreams: true,
+		},
+	},
+}
+
+func streamRenderLogHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(commanderservice.GetBlenderFarmWorkflowRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+
+	return srv.(*server).StreamRenderLog(req, stream)
+}
+
+// StreamRenderLog sends the archived render log of a finished batch.
+func (s *server) StreamRenderLog(req *commanderservice.GetBlenderFarmWorkflowRequest, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+
+	index, err := batchFromContext(ctx)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	summary, err := s.querySummary(ctx, req.Id)
+	if err != nil {
+		return err
+	}
+
+	if index < 0 || index >= summary.BatchesTotal {
+		return status.Errorf(codes.NotFound, "job %s has no batch %d", req.Id, index)
+	}
+
+	page, err := s.queryBatches(ctx, req.Id, "", index, 1)
+	if err != nil {
+		return err
+	}
+
+	if len(page.Batches) == 0 {
+		return status.Errorf(codes.NotFound, "job %s has no batch %d", req.Id, index)
+	}
+
+	batch := page.Batches[0]
+	if batch.Status == blenderfarm.BatchPending || batch.Status == blenderfarm.BatchRunning {
+		return status.Errorf(codes.FailedPrecondition, "batch %d is %s, its render log is archived once it finishes", index, batch.Status)
+	}
+
+	logArtifact, err := s.artifactClient.DownloadArtifact(ctx, blendernode.LogArtifactName(summary.Job, summary.Artifact, batch.Frames))
+	if err != nil {
+		return status.Errorf(codes.NotFound, "no render log archived for batch %d: %v", index, err)
+	}
+
+	dir, err := os.MkdirTemp("", "renderlog-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := logArtifact.ExtractToDirectory(dir); err != nil {
+		return err
+	}
+
+	file, err := os.Open(filepath.Join(dir, renderlog.FileName))
+	if err != nil {
+		return status.Errorf(codes.NotFound, "no render log archived for batch %d: %v", index, err)
+	}
+	defer file.Close()
+
+	buf := make([]byte, renderLogChunkSize)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			if err := stream.SendMsg(wrapperspb.Bytes(buf[:n])); err != nil {
+				return err
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// StreamRenderLog copies the render log of batch of job to w from a frontend reachable through conn.
+func StreamRenderLog(ctx context.Context, conn grpc.ClientConnInterface, job string, batch int, w io.Writer) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, MetadataBatch, fmt.Sprintf("%d", batch))
+
+	stream, err := conn.NewStream(ctx, &renderLogServiceDesc.Streams[0], StreamRenderLogMethod)
+	if err != nil {
+		return err
+	}
+
+	if err := stream.SendMsg(&commanderservice.GetBlenderFarmWorkflowRequest{Id: job}); err != nil {
+		return err
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		chunk := new(wrapperspb.BytesValue)
+		err := stream.RecvMsg(chunk)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(chunk.Value); err != nil {
+			return err
+		}
+	}
+}
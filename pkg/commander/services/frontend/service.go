@@ -1,31 +1,81 @@
 package frontend
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"net"
+	"net/http"
+	"time"
 
 	"github.com/flowshot-io/commander-client-go/commanderservice/v1"
+	"github.com/flowshot-io/commander/pkg/commander/auth"
+	"github.com/flowshot-io/commander/pkg/commander/listener"
+	"github.com/flowshot-io/x/pkg/artifactservice"
 	"github.com/flowshot-io/x/pkg/logger"
 	"github.com/flowshot-io/x/pkg/manager"
 	"go.temporal.io/sdk/client"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
+// DefaultPort is used when the listener does not set a port or Unix socket.
+const DefaultPort = 50051
+
 type Options struct {
 	TemporalClient client.Client
+	// Namespace is the Temporal namespace the frontend must reach to report itself healthy.
+	Namespace string
+	// ArtifactClient fetches the render logs archived by the blendernodes.
+	ArtifactClient artifactservice.ArtifactServiceClient
 	Logger         logger.Logger
+	// Authenticator authenticates every call. Calls are not authenticated when it is nil.
+	Authenticator auth.Authenticator
+	// Listener configures where the frontend listens and the limits it puts on clients.
+	Listener listener.Options
+	// Gateway serves the HTTP/JSON gateway to the API on this listener. The gateway is disabled when it is nil.
+	Gateway *listener.Options
+	// TLS serves the frontend over TLS. The frontend listens in plaintext when it is nil.
+	TLS *tls.Config
+	// MaxParallelBatches is the default applied to jobs that do not set their own limit.
+	MaxParallelBatches int
+	// HistoryThreshold is the history length after which jobs continue as new.
+	HistoryThreshold int
+	// CacheRoutingTimeout is how long batches wait for a node that has the project cached.
+	CacheRoutingTimeout time.Duration
+	// MaxBatchAttempts and MaxActivityAttempts cap the retries of transient failures of jobs.
+	MaxBatchAttempts    int
+	MaxActivityAttempts int
 }
 
 type Service struct {
-	Server  *grpc.Server
-	Logger  logger.Logger
-	Port    int
-	ErrChan chan error
+	Server   *grpc.Server
+	Health   *health.Server
+	Logger   logger.Logger
+	Listener listener.Options
+	ErrChan  chan error
+	// Gateway serves the HTTP/JSON gateway, it is nil when the gateway is disabled.
+	Gateway         *http.Server
+	GatewayListener listener.Options
+
+	temporal   client.Client
+	namespace  string
+	healthStop chan struct{}
+	healthDone chan struct{}
 }
 
 type server struct {
 	commanderservice.CommanderServiceServer
-	temporal client.Client
+	temporal            client.Client
+	artifactClient      artifactservice.ArtifactServiceClient
+	maxParallelBatches  int
+	historyThreshold    int
+	cacheRoutingTimeout time.Duration
+	maxBatchAttempts    int
+	maxActivityAttempts int
 }
 
 func New(opts Options) (manager.Service, error) {
@@ -37,27 +87,126 @@ func New(opts Options) (manager.Service, error) {
 		return nil, fmt.Errorf("temporal client is required")
 	}
 
-	srv := grpc.NewServer()
-	commanderservice.RegisterCommanderServiceServer(srv, &server{
-		temporal: opts.TemporalClient,
-	})
+	if opts.ArtifactClient == nil {
+		return nil, fmt.Errorf("artifact client is required")
+	}
+
+	if opts.Namespace == "" {
+		opts.Namespace = client.DefaultNamespace
+	}
+
+	impl := &server{
+		temporal:            opts.TemporalClient,
+		artifactClient:      opts.ArtifactClient,
+		maxParallelBatches:  opts.MaxParallelBatches,
+		historyThreshold:    opts.HistoryThreshold,
+		cacheRoutingTimeout: opts.CacheRoutingTimeout,
+		maxBatchAttempts:    opts.MaxBatchAttempts,
+		maxActivityAttempts: opts.MaxActivityAttempts,
+	}
+
+	if opts.Listener.Port == 0 && opts.Listener.UnixSocket == "" {
+		opts.Listener.Port = DefaultPort
+	}
+
+	serverOptions := listener.ServerOptions(opts.Listener)
+	var authOptions *auth.Options
+	if opts.Authenticator != nil {
+		authOptions = &auth.Options{Authenticator: opts.Authenticator, PublicMethods: healthMethods}
+		serverOptions = append(serverOptions,
+			grpc.ChainUnaryInterceptor(auth.UnaryServerInterceptor(*authOptions)),
+			grpc.ChainStreamInterceptor(auth.StreamServerInterceptor(*authOptions)),
+		)
+	} else {
+		opts.Logger.Warn("Frontend authentication is disabled, any caller can submit and cancel renders")
+	}
+
+	if opts.TLS != nil {
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(opts.TLS)))
+	} else {
+		opts.Logger.Warn("Frontend TLS is disabled, calls are sent in plaintext")
+	}
+
+	serverOptions = append(serverOptions,
+		grpc.ChainUnaryInterceptor(errorUnaryInterceptor),
+		grpc.ChainStreamInterceptor(errorStreamInterceptor),
+	)
+
+	srv := grpc.NewServer(serverOptions...)
+	commanderservice.RegisterCommanderServiceServer(srv, impl)
+	srv.RegisterService(&renderLogServiceDesc, impl)
+	srv.RegisterService(&outputArtifactServiceDesc, impl)
+
+	healthSrv := health.NewServer()
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	reflection.Register(srv)
 
 	s := &Service{
-		Server:  srv,
-		Logger:  opts.Logger,
-		Port:    50051,
-		ErrChan: make(chan error),
+		Server:    srv,
+		Health:    healthSrv,
+		Logger:    opts.Logger,
+		Listener:  opts.Listener,
+		ErrChan:   make(chan error),
+		temporal:  opts.TemporalClient,
+		namespace: opts.Namespace,
+	}
+	s.setServingStatus(false)
+
+	if opts.Gateway != nil {
+		s.GatewayListener = *opts.Gateway
+		if s.GatewayListener.Port == 0 && s.GatewayListener.UnixSocket == "" {
+			s.GatewayListener.Port = DefaultGatewayPort
+		}
+
+		maxBodySize := int64(defaultGatewayMaxBodySize)
+		if s.GatewayListener.MaxRecvMsgSize > 0 {
+			maxBodySize = int64(s.GatewayListener.MaxRecvMsgSize)
+		}
+
+		s.Gateway = &http.Server{
+			Handler:           &gateway{impl: impl, authOptions: authOptions, maxBodySize: maxBodySize},
+			ReadHeaderTimeout: gatewayReadHeaderTimeout,
+		}
+		if opts.TLS != nil {
+			s.Gateway.TLSConfig = gatewayTLSConfig(opts.TLS)
+		}
 	}
 
 	return s, nil
 }
 
 func (s *Service) Start() error {
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
+	lis, err := listener.Listen(s.Listener)
 	if err != nil {
-		return fmt.Errorf("failed to listen: %v", err)
+		return fmt.Errorf("failed to listen on %s: %v", s.Listener, err)
 	}
 
+	s.Logger.Info("Frontend listening", map[string]interface{}{"Address": lis.Addr().String()})
+
+	if s.Gateway != nil {
+		gatewayLis, err := listener.Listen(s.GatewayListener)
+		if err != nil {
+			lis.Close()
+			return fmt.Errorf("failed to listen on %s: %v", s.GatewayListener, err)
+		}
+
+		if s.Gateway.TLSConfig != nil {
+			gatewayLis = tls.NewListener(gatewayLis, s.Gateway.TLSConfig)
+		}
+
+		s.Logger.Info("Frontend gateway listening", map[string]interface{}{"Address": gatewayLis.Addr().String()})
+
+		go func() {
+			if err := s.Gateway.Serve(gatewayLis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.ErrChan <- fmt.Errorf("failed to serve gateway: %v", err)
+			}
+		}()
+	}
+
+	s.healthStop = make(chan struct{})
+	s.healthDone = make(chan struct{})
+	go s.watchHealth(s.healthStop)
+
 	go func() {
 		if err := s.Server.Serve(lis); err != nil {
 			s.ErrChan <- fmt.Errorf("failed to serve: %v", err)
@@ -68,6 +217,22 @@ func (s *Service) Start() error {
 }
 
 func (s *Service) Stop() error {
+	// Report not serving for the rest of the shutdown so load balancers drain the frontend.
+	s.Health.Shutdown()
+	if s.healthStop != nil {
+		close(s.healthStop)
+		<-s.healthDone
+	}
+
+	if s.Gateway != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), gatewayShutdownTimeout)
+		defer cancel()
+
+		if err := s.Gateway.Shutdown(ctx); err != nil {
+			s.Logger.Warn("Unable to shut down the gateway gracefully", map[string]interface{}{"Error": err.Error()})
+		}
+	}
+
 	s.Server.GracefulStop()
 	close(s.ErrChan)
 	return nil
@@ -0,0 +1,63 @@
+package frontend
+
+import (
+	"github.com/flowshot-io/commander-client-go/commanderservice/v1"
+	"github.com/flowshot-io/commander/pkg/commander/services/blenderfarm"
+	enumspb "go.temporal.io/api/enums/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// renderStatusFromExecution maps a visibility record to a RenderStatus.
+func renderStatusFromExecution(info *workflowpb.WorkflowExecutionInfo) *commanderservice.RenderStatus {
+	status := &commanderservice.RenderStatus{
+		Id:     info.GetExecution().GetWorkflowId(),
+		Status: renderStatusFromExecutionStatus(info.GetStatus()),
+	}
+
+	if payload, ok := info.GetMemo().GetFields()[memoArtifact]; ok {
+		var artifact string
+		if err := converter.GetDefaultDataConverter().FromPayload(payload, &artifact); err == nil {
+			status.File = artifact
+		}
+	}
+
+	return status
+}
+
+func renderStatusFromExecutionStatus(status enumspb.WorkflowExecutionStatus) commanderservice.RenderStatus_Status {
+	switch status {
+	case enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING, enumspb.WORKFLOW_EXECUTION_STATUS_CONTINUED_AS_NEW:
+		return commanderservice.RenderStatus_RUNNING
+	case enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED:
+		return commanderservice.RenderStatus_SUCCESS
+	case enumspb.WORKFLOW_EXECUTION_STATUS_FAILED,
+		enumspb.WORKFLOW_EXECUTION_STATUS_CANCELED,
+		enumspb.WORKFLOW_EXECUTION_STATUS_TERMINATED,
+		enumspb.WORKFLOW_EXECUTION_STATUS_TIMED_OUT:
+		return commanderservice.RenderStatus_FAILED
+	default:
+		return commanderservice.RenderStatus_UNKNOWN
+	}
+}
+
+// renderStatusFromSummary maps the result of the blenderfarm query to a RenderStatus.
+func renderStatusFromSummary(id string, summary blenderfarm.Summary) *commanderservice.RenderStatus {
+	status := &commanderservice.RenderStatus{
+		Id:   id,
+		File: summary.Artifact,
+	}
+
+	switch summary.Status {
+	case blenderfarm.BatchPending:
+		status.Status = commanderservice.RenderStatus_PENDING
+	case blenderfarm.BatchRunning:
+		status.Status = commanderservice.RenderStatus_RUNNING
+	case blenderfarm.BatchDone:
+		status.Status = commanderservice.RenderStatus_SUCCESS
+	case blenderfarm.BatchFailed, blenderfarm.BatchCanceled:
+		status.Status = commanderservice.RenderStatus_FAILED
+	}
+
+	return status
+}
@@ -0,0 +1,46 @@
+//go:build !windows
+
+package supervisor
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminateGroup sends SIGTERM to every process in the group led by pid.
+func terminateGroup(pid int) error {
+	return signalGroup(pid, syscall.SIGTERM)
+}
+
+// killGroup sends SIGKILL to every process in the group led by pid.
+func killGroup(pid int) error {
+	return signalGroup(pid, syscall.SIGKILL)
+}
+
+// cleanupGroup kills the processes left in the group led by pid once pid has exited.
+func cleanupGroup(pid int) {
+	_ = killGroup(pid)
+}
+
+func signalGroup(pid int, sig syscall.Signal) error {
+	err := syscall.Kill(-pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+
+	return err
+}
+
+func exitStatus(state *os.ProcessState) (int, string) {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return -1, status.Signal().String()
+	}
+
+	return state.ExitCode(), ""
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package supervisor
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// terminateGroup asks the process tree of pid to close.
+func terminateGroup(pid int) error {
+	return exec.Command("taskkill", "/T", "/PID", strconv.Itoa(pid)).Run()
+}
+
+// killGroup forcefully ends the process tree of pid.
+func killGroup(pid int) error {
+	return exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(pid)).Run()
+}
+
+// cleanupGroup does nothing, as the process tree of pid can no longer be found once pid has exited
+// and the pid may already have been reused.
+func cleanupGroup(pid int) {}
+
+func exitStatus(state *os.ProcessState) (int, string) {
+	return state.ExitCode(), ""
+}
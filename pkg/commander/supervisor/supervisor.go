@@ -0,0 +1,185 @@
+// Package supervisor runs an external process, such as the renderer, in its
+// own process group so that it and every process it spawns can be stopped
+// together.
+//
+// Stopping a process first asks the group to terminate and only kills it once
+// the grace period has passed. Processes left behind in the group when the
+// process itself exits are killed as well.
+package supervisor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// DefaultGracePeriod is used when Options does not set GracePeriod.
+const DefaultGracePeriod = 10 * time.Second
+
+type (
+	Options struct {
+		Name string
+		Args []string
+		Dir  string
+		// GracePeriod is how long Stop waits after asking the process group to
+		// terminate before killing it.
+		GracePeriod time.Duration
+	}
+
+	// ExitError is returned when a supervised process does not exit cleanly.
+	ExitError struct {
+		Name string
+		// Code is the exit code of the process, or -1 when it was ended by a signal.
+		Code int
+		// Signal is the signal that ended the process, if any.
+		Signal string
+		// Stopped is set when the process was ended by Stop.
+		Stopped bool
+	}
+
+	// Process is a running supervised process.
+	Process struct {
+		cmd         *exec.Cmd
+		output      *os.File
+		gracePeriod time.Duration
+
+		stop     chan struct{}
+		stopOnce sync.Once
+		stopped  bool
+		done     chan struct{}
+		err      error
+	}
+)
+
+// Start runs the process in a new process group. Its combined stdout and
+// stderr are read from Output.
+func Start(opts Options) (*Process, error) {
+	if opts.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	if opts.GracePeriod <= 0 {
+		opts.GracePeriod = DefaultGracePeriod
+	}
+
+	// An *os.File is handed to the process directly, so waiting for it does not also
+	// wait for processes it spawned that still hold the output open.
+	output, outputWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(opts.Name, opts.Args...)
+	cmd.Dir = opts.Dir
+	cmd.Stdout = outputWriter
+	cmd.Stderr = outputWriter
+	setProcessGroup(cmd)
+
+	err = cmd.Start()
+	outputWriter.Close()
+	if err != nil {
+		output.Close()
+		return nil, err
+	}
+
+	p := &Process{
+		cmd:         cmd,
+		output:      output,
+		gracePeriod: opts.GracePeriod,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	go p.wait(opts.Name)
+
+	return p, nil
+}
+
+// Output returns the combined stdout and stderr of the process. It reaches EOF
+// once every process in the group has exited.
+func (p *Process) Output() io.ReadCloser {
+	return p.output
+}
+
+// Done is closed once the process has exited.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err returns nil if the process exited cleanly and an *ExitError otherwise. It
+// is only valid once Done is closed.
+func (p *Process) Err() error {
+	return p.err
+}
+
+// Stop asks the process group to terminate and kills it if it has not exited
+// within the grace period. It waits for the process to exit and returns Err. It
+// is safe to call Stop more than once and from several goroutines.
+func (p *Process) Stop() error {
+	select {
+	case <-p.done:
+		return p.err
+	default:
+	}
+
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+
+	<-p.done
+	return p.err
+}
+
+// String returns the command line of the process.
+func (p *Process) String() string {
+	return p.cmd.String()
+}
+
+func (p *Process) wait(name string) {
+	exited := make(chan error, 1)
+	go func() {
+		exited <- p.cmd.Wait()
+	}()
+
+	var err error
+	select {
+	case err = <-exited:
+	case <-p.stop:
+		p.stopped = true
+		_ = terminateGroup(p.cmd.Process.Pid)
+
+		grace := time.NewTimer(p.gracePeriod)
+		select {
+		case err = <-exited:
+			grace.Stop()
+		case <-grace.C:
+			_ = killGroup(p.cmd.Process.Pid)
+			err = <-exited
+		}
+	}
+
+	// Nothing spawned by the process is left running once it has exited.
+	cleanupGroup(p.cmd.Process.Pid)
+
+	if err != nil {
+		exitErr := &ExitError{Name: name, Code: -1, Stopped: p.stopped}
+		if p.cmd.ProcessState != nil {
+			exitErr.Code, exitErr.Signal = exitStatus(p.cmd.ProcessState)
+		}
+
+		p.err = exitErr
+	}
+
+	close(p.done)
+}
+
+func (e *ExitError) Error() string {
+	if e.Signal != "" {
+		return fmt.Sprintf("%s was ended by signal: %s", e.Name, e.Signal)
+	}
+
+	return fmt.Sprintf("%s exited with code %d", e.Name, e.Code)
+}
@@ -0,0 +1,208 @@
+//go:build !windows
+
+package supervisor
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func start(t *testing.T, script string, gracePeriod time.Duration) *Process {
+	t.Helper()
+
+	p, err := Start(Options{Name: "sh", Args: []string{"-c", script}, GracePeriod: gracePeriod})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		p.Stop()
+		p.Output().Close()
+	})
+
+	return p
+}
+
+// waitForLine reads the output of p until it prints line.
+func waitForLine(t *testing.T, p *Process, line string) *bufio.Reader {
+	t.Helper()
+
+	r := bufio.NewReader(p.Output())
+	got, err := r.ReadString('\n')
+	if err != nil || strings.TrimSpace(got) != line {
+		t.Fatalf("output = %q, %v, want %q", got, err, line)
+	}
+
+	return r
+}
+
+func exitError(t *testing.T, err error) *ExitError {
+	t.Helper()
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("err = %v, want an *ExitError", err)
+	}
+
+	return exitErr
+}
+
+func TestStartRequiresName(t *testing.T) {
+	if _, err := Start(Options{}); err == nil {
+		t.Error("Start() = nil error, want an error")
+	}
+}
+
+func TestExit(t *testing.T) {
+	p := start(t, "echo out; echo err >&2", 0)
+
+	output, err := io.ReadAll(p.Output())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-p.Done()
+	if p.Err() != nil {
+		t.Errorf("Err() = %v, want nil", p.Err())
+	}
+
+	if string(output) != "out\nerr\n" {
+		t.Errorf("output = %q, want %q", output, "out\nerr\n")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	p := start(t, "exit 3", 0)
+	<-p.Done()
+
+	exitErr := exitError(t, p.Err())
+	if exitErr.Code != 3 || exitErr.Signal != "" || exitErr.Stopped {
+		t.Errorf("Err() = %+v, want code 3 without a signal", exitErr)
+	}
+
+	if exitErr.Error() != "sh exited with code 3" {
+		t.Errorf("Error() = %q", exitErr.Error())
+	}
+}
+
+func TestExitSignal(t *testing.T) {
+	p := start(t, "kill -KILL $$", 0)
+	<-p.Done()
+
+	exitErr := exitError(t, p.Err())
+	if exitErr.Code != -1 || exitErr.Signal != "killed" || exitErr.Stopped {
+		t.Errorf("Err() = %+v, want code -1 and signal killed without Stop", exitErr)
+	}
+}
+
+func TestStopTerminates(t *testing.T) {
+	p := start(t, "echo ready; sleep 30", time.Minute)
+	waitForLine(t, p, "ready")
+
+	began := time.Now()
+	exitErr := exitError(t, p.Stop())
+	if exitErr.Code != -1 || exitErr.Signal != "terminated" || !exitErr.Stopped {
+		t.Errorf("Stop() = %+v, want code -1, signal terminated and stopped", exitErr)
+	}
+
+	if elapsed := time.Since(began); elapsed > 10*time.Second {
+		t.Errorf("Stop() took %v, want it to exit on SIGTERM", elapsed)
+	}
+}
+
+func TestStopKillsAfterGracePeriod(t *testing.T) {
+	// Ignoring SIGTERM is inherited by the sleeps, so only SIGKILL ends the group.
+	const gracePeriod = 200 * time.Millisecond
+	p := start(t, `trap "" TERM; echo ready; while :; do sleep 1; done`, gracePeriod)
+	waitForLine(t, p, "ready")
+
+	began := time.Now()
+	exitErr := exitError(t, p.Stop())
+	if exitErr.Code != -1 || exitErr.Signal != "killed" || !exitErr.Stopped {
+		t.Errorf("Stop() = %+v, want code -1, signal killed and stopped", exitErr)
+	}
+
+	if elapsed := time.Since(began); elapsed < gracePeriod {
+		t.Errorf("Stop() took %v, want at least the grace period of %v", elapsed, gracePeriod)
+	}
+}
+
+func TestStopKillsGroup(t *testing.T) {
+	// The sleep holds the output open, so the output only reaches EOF once the whole group has exited.
+	p := start(t, "sleep 30 & echo ready; wait", time.Minute)
+	r := waitForLine(t, p, "ready")
+
+	p.Stop()
+
+	read := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(r)
+		read <- err
+	}()
+
+	select {
+	case err := <-read:
+		if err != nil {
+			t.Errorf("reading output: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Error("output did not reach EOF, a process of the group is still running")
+	}
+}
+
+func TestExitKillsLeftoverProcesses(t *testing.T) {
+	// The shell exits right away and leaves the sleep behind, which is killed when the shell exits.
+	p := start(t, "sleep 30 & echo ready", time.Minute)
+	r := waitForLine(t, p, "ready")
+
+	read := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(r)
+		read <- err
+	}()
+
+	select {
+	case <-read:
+	case <-time.After(10 * time.Second):
+		t.Error("output did not reach EOF, the process left behind is still running")
+	}
+
+	<-p.Done()
+	if p.Err() != nil {
+		t.Errorf("Err() = %v, want nil", p.Err())
+	}
+}
+
+func TestStopAfterExit(t *testing.T) {
+	p := start(t, "exit 2", 0)
+	<-p.Done()
+
+	exitErr := exitError(t, p.Stop())
+	if exitErr.Code != 2 || exitErr.Stopped {
+		t.Errorf("Stop() = %+v, want code 2 without being stopped", exitErr)
+	}
+}
+
+func TestStopConcurrently(t *testing.T) {
+	p := start(t, "echo ready; sleep 30", time.Minute)
+	waitForLine(t, p, "ready")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var exitErr *ExitError
+			if err := p.Stop(); !errors.As(err, &exitErr) || !exitErr.Stopped {
+				t.Errorf("Stop() = %v, want a stopped *ExitError", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,59 @@
+package temporalactivities
+
+import (
+	"context"
+	"errors"
+
+	"github.com/flowshot-io/commander/pkg/commander/artifactcache"
+	"github.com/flowshot-io/commander/pkg/commander/workspace"
+	"github.com/flowshot-io/x/pkg/artifactservice"
+	xactivities "github.com/flowshot-io/x/pkg/temporalactivities"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// ArtifactActivities serves PullArtifact from the node's artifact cache and
+// pushes artifacts straight to the artifact service.
+type ArtifactActivities struct {
+	artifacts  *xactivities.ArtifactActivities
+	cache      *artifactcache.Cache
+	workspaces *workspace.Manager
+}
+
+func NewArtifactActivities(artifactClient artifactservice.ArtifactServiceClient, cache *artifactcache.Cache, workspaces *workspace.Manager) *ArtifactActivities {
+	return &ArtifactActivities{
+		artifacts:  xactivities.NewArtifactActivities(artifactClient),
+		cache:      cache,
+		workspaces: workspaces,
+	}
+}
+
+// PullArtifact extracts the specified artifact to a local directory, downloading it only on a cache miss.
+// When the directory is a workspace, its reservation is grown to the size of the archive before the
+// artifact is extracted, as a project that was not cached has only the default size reserved for it.
+func (a *ArtifactActivities) PullArtifact(ctx context.Context, artifactName string, destinationPath string) error {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Pulling artifact...", "Artifact", artifactName, "Destination", destinationPath)
+
+	reserve := func(size int64) error {
+		return a.workspaces.Grow(destinationPath, size*extractRatio)
+	}
+
+	err := a.cache.Pull(ctx, artifactName, destinationPath, reserve)
+	if errors.Is(err, workspace.ErrInsufficientSpace) {
+		// Like a workspace that cannot be created, this is not retried on the same node.
+		logger.Warn("PullArtifact has no room for artifact.", "Error", err)
+		return temporal.NewNonRetryableApplicationError(err.Error(), ErrInsufficientSpace, err)
+	}
+	if err != nil {
+		logger.Error("PullArtifact failed to pull artifact.", "Error", err)
+		return classifyPullError(err)
+	}
+
+	return nil
+}
+
+// PushArtifact creates an artifact from the specified files and uploads it to the artifact service.
+func (a *ArtifactActivities) PushArtifact(ctx context.Context, artifactName string, files []string) error {
+	return a.artifacts.PushArtifact(ctx, artifactName, files)
+}
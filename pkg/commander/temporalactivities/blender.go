@@ -1,29 +1,137 @@
 package temporalactivities
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"os/exec"
+	"io"
+	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/flowshot-io/commander/pkg/commander/frames"
+	"github.com/flowshot-io/commander/pkg/commander/renderer"
+	"github.com/flowshot-io/commander/pkg/commander/renderlog"
+	"github.com/flowshot-io/commander/pkg/commander/renderoutput"
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	// ErrInvalidOutput is the application error type returned when the frames of a render fail validation.
+	// Its details are the renderoutput.FrameProblem of every bad frame.
+	ErrInvalidOutput = "InvalidOutput"
+	// ErrNodeBusy is the application error type returned when the node's render slot is not free within
+	// the slot timeout of the render.
+	ErrNodeBusy = "NodeBusy"
+
+	// RenderProgressSignal carries the renderlog.Progress of RenderFramesActivity to the workflow that started it.
+	RenderProgressSignal = "blendernode-render-progress"
+	// progressSignalInterval is the least time between two progress signals, as every signal is
+	// an event in the history of the node workflow.
+	progressSignalInterval = 2 * time.Minute
+
+	// renderLogMaxSize bounds the render log kept for a batch.
+	renderLogMaxSize = 64 << 20
+	// renderLogTailLines is the number of lines of the render log added to the error of a failed render.
+	renderLogTailLines = 20
 )
 
 type BlenderActivities struct {
+	temporalClient client.Client
+	// renderers are the backends jobs can choose from, defaultRenderer is used when a job does not.
+	renderers       map[string]renderer.Renderer
+	defaultRenderer string
+	validation      renderoutput.Options
+	// slot allows one render at a time on the node, even when sessions are
+	// created on both the shared and the host task queue.
+	slot chan struct{}
 }
 
-func NewBlenderActivities() *BlenderActivities {
-	return &BlenderActivities{}
+func NewBlenderActivities(temporalClient client.Client, renderers map[string]renderer.Renderer, defaultRenderer string, validation renderoutput.Options) *BlenderActivities {
+	return &BlenderActivities{
+		temporalClient:  temporalClient,
+		renderers:       renderers,
+		defaultRenderer: defaultRenderer,
+		validation:      validation,
+		slot:            make(chan struct{}, 1),
+	}
 }
 
+// RenderProjectActivity renders startFrame to endFrame of the project in workingDir with the node's
+// default renderer. It keeps the signature batches started before RenderFramesActivity schedule it
+// with, so their retried and pending tasks still decode.
 func (a *BlenderActivities) RenderProjectActivity(ctx context.Context, workingDir string, startFrame int, endFrame int) (string, error) {
+	return a.RenderFramesActivity(ctx, workingDir, frames.Contiguous(startFrame, endFrame), "", 0)
+}
+
+// RenderFramesActivity renders frameSpec of the project in workingDir with the renderer called
+// rendererName, or the node's default renderer when it is empty, and returns the output directory.
+// The render waits at most slotTimeout for another render on the node to finish, or as long as it
+// takes when slotTimeout is zero.
+func (a *BlenderActivities) RenderFramesActivity(ctx context.Context, workingDir string, frameSpec frames.Spec, rendererName string, slotTimeout time.Duration) (string, error) {
 	logger := activity.GetLogger(ctx)
-	logger.Info("Rendering project...", "WorkingDir", workingDir, "StartFrame", startFrame, "EndFrame", endFrame)
 
-	output, err := renderFile(ctx, workingDir, startFrame, endFrame)
+	if rendererName == "" {
+		rendererName = a.defaultRenderer
+	}
+
+	r, ok := a.renderers[rendererName]
+	if !ok {
+		// Retrying on this node cannot help, but another node of the farm may have the renderer.
+		return "", temporal.NewNonRetryableApplicationError(fmt.Sprintf("renderer %q is not available on this node", rendererName), ErrUnknownRenderer, nil)
+	}
+
+	logger.Info("Rendering project...", "WorkingDir", workingDir, "Frames", frameSpec.String(), "Renderer", rendererName)
+
+	progress := &renderProgress{
+		temporalClient: a.temporalClient,
+		parser:         renderlog.NewParser(frameSpec.Len()),
+	}
+
+	// A retried attempt only renders the frames the previous attempts did not save.
+	remaining := frameSpec
+	if previous, ok := resumeProgress(ctx, workingDir); ok {
+		progress.parser.Resume(previous)
+		remaining = missingFrames(frameSpec, previous)
+		logger.Info("Resuming render.", "FramesSaved", len(previous.Saved), "Remaining", remaining.String())
+	}
+
+	renderLog, err := renderlog.NewCapture(filepath.Join(workingDir, renderlog.Dir, renderlog.FileName), renderLogMaxSize, renderLogTailLines)
+	if err != nil {
+		return "", fmt.Errorf("unable to create render log: %w", err)
+	}
+	defer renderLog.Close()
+
+	if err := a.acquire(ctx, progress, slotTimeout); err != nil {
+		return "", err
+	}
+	defer a.release()
+
+	// Renderers only render contiguous ranges, so render each run of consecutive frames in turn.
+	for _, run := range remaining.Runs() {
+		if err := renderFile(ctx, r, workingDir, run.Start, run.End, progress, renderLog); err != nil {
+			logger.Error("RenderFileActivity failed to render project.", "Error", err)
+			if ctx.Err() == context.Canceled {
+				return "", err
+			}
+
+			return "", classifyRenderError(err, renderLog.Tail())
+		}
+	}
+
+	output := filepath.Join(workingDir, renderer.OutputDir)
+
+	// A renderer can exit cleanly without writing every frame, which must not be pushed as a finished batch.
+	err = renderoutput.Validate(output, frameSpec, savedFiles(progress.parser.Progress(), workingDir), a.validation)
+	var invalid *renderoutput.Error
+	if errors.As(err, &invalid) {
+		logger.Error("RenderFileActivity rendered invalid output.", "Error", err)
+		return "", temporal.NewNonRetryableApplicationError(err.Error(), ErrInvalidOutput, err, invalid.Frames)
+	}
 	if err != nil {
-		logger.Error("RenderFileActivity failed to render project.", "Error", err)
 		return "", err
 	}
 
@@ -31,51 +139,233 @@ func (a *BlenderActivities) RenderProjectActivity(ctx context.Context, workingDi
 	return output, nil
 }
 
-func renderFile(ctx context.Context, workingDir string, frameStart int, frameEnd int) (string, error) {
-	logger := activity.GetLogger(ctx)
+// acquire waits for the node's render slot, heartbeating while another render holds it. It gives up
+// with ErrNodeBusy once timeout has passed, unless timeout is zero.
+func (a *BlenderActivities) acquire(ctx context.Context, progress *renderProgress, timeout time.Duration) error {
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
 
-	logger.Info("renderFileActivity starting...", "WorkingDir", workingDir, "FrameStart", frameStart, "FrameEnd", frameEnd)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
 
-	args := []string{
-		"render",
-		"-d", workingDir,
-		"-s", fmt.Sprintf("%d", frameStart),
-		"-e", fmt.Sprintf("%d", frameEnd),
+	for {
+		select {
+		case a.slot <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-expired:
+			return temporal.NewNonRetryableApplicationError(fmt.Sprintf("node is still rendering another batch after %s", timeout), ErrNodeBusy, nil)
+		case <-heartbeat.C:
+			progress.heartbeat(ctx)
+		}
 	}
+}
 
-	cmd := exec.CommandContext(ctx, "rocketblend", args...)
-	logger.Info("renderFileActivity executing command.", "Command", cmd.String())
+func (a *BlenderActivities) release() {
+	<-a.slot
+}
 
-	err := cmd.Start()
-	if err != nil {
-		return "", err
-	}
-	defer cmd.Process.Kill()
+func renderFile(ctx context.Context, r renderer.Renderer, workingDir string, frameStart int, frameEnd int, progress *renderProgress, renderLog *renderlog.Capture) error {
+	logger := activity.GetLogger(ctx)
+
+	logger.Info("renderFileActivity starting...", "WorkingDir", workingDir, "FrameStart", frameStart, "FrameEnd", frameEnd)
+
+	output, outputWriter := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		err := r.Render(ctx, workingDir, frameStart, frameEnd, outputWriter)
+		outputWriter.Close()
+		done <- err
+	}()
 
-	done := make(chan error)
+	// Lines are handed to the loop below so the parser is only used from one goroutine.
+	lines := make(chan string)
 	go func() {
-		done <- cmd.Wait()
+		defer close(lines)
+
+		scanner := bufio.NewScanner(output)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+
+		// Keep draining so the renderer never blocks on a full pipe.
+		_, _ = io.Copy(io.Discard, output)
 	}()
 
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	progress.heartbeat(ctx)
+
+	// The log is closed once the renderer returns, which it does straight away when ctx is done,
+	// so heartbeats carry on while a canceled render is stopping.
 OuterLoop:
 	for {
 		select {
-		case <-ctx.Done():
-			logger.Info("renderFileActivity command cancelled.")
-			return "", ctx.Err()
-		case err := <-done:
-			if err != nil {
-				return "", err
+		case line, ok := <-lines:
+			if !ok {
+				break OuterLoop
 			}
-			// Command has finished
-			break OuterLoop
-		default:
-			activity.RecordHeartbeat(ctx, time.Now())
-			time.Sleep(30 * time.Second)
+
+			progress.parse(ctx, line)
+			if err := renderLog.Line(line); err != nil {
+				logger.Warn("Unable to write render log.", "Error", err)
+			}
+		case <-heartbeat.C:
+			progress.heartbeat(ctx)
+			progress.signal(ctx)
 		}
 	}
 
+	err := <-done
+
+	progress.heartbeat(ctx)
+	progress.flush(ctx)
+
+	if ctx.Err() != nil {
+		logger.Info("renderFileActivity command cancelled.", "Error", err)
+		return ctx.Err()
+	}
+
+	if err != nil {
+		return err
+	}
+
 	logger.Info("renderFileActivity command finished.")
 
-	return filepath.Join(workingDir, "output"), nil
+	return nil
+}
+
+// resumeProgress returns the progress recorded in the heartbeat of the previous attempt, keeping
+// only the saved frames whose output is still in the workspace.
+func resumeProgress(ctx context.Context, workingDir string) (renderlog.Progress, bool) {
+	if !activity.HasHeartbeatDetails(ctx) {
+		return renderlog.Progress{}, false
+	}
+
+	var previous renderlog.Progress
+	if err := activity.GetHeartbeatDetails(ctx, &previous); err != nil {
+		activity.GetLogger(ctx).Warn("Unable to read previous render progress.", "Error", err)
+		return renderlog.Progress{}, false
+	}
+
+	kept := map[int]bool{}
+	saved := previous.Saved[:0]
+	for _, frame := range previous.Saved {
+		if _, err := os.Stat(savedPath(frame, workingDir)); err == nil {
+			saved = append(saved, frame)
+			kept[frame.Frame] = true
+		}
+	}
+
+	frameTimes := previous.FrameTimes[:0]
+	for _, ft := range previous.FrameTimes {
+		if kept[ft.Frame] {
+			frameTimes = append(frameTimes, ft)
+		}
+	}
+
+	previous.Saved = saved
+	previous.FrameTimes = frameTimes
+	previous.FramesCompleted = len(kept)
+	return previous, true
+}
+
+// savedFiles maps the frames the render log reported as saved to their files.
+func savedFiles(progress renderlog.Progress, workingDir string) map[int]string {
+	files := make(map[int]string, len(progress.Saved))
+	for _, frame := range progress.Saved {
+		files[frame.Frame] = savedPath(frame, workingDir)
+	}
+
+	return files
+}
+
+// savedPath resolves the output of a saved frame, which Blender may report relative to the project.
+func savedPath(frame renderlog.SavedFrame, workingDir string) string {
+	if filepath.IsAbs(frame.Output) {
+		return frame.Output
+	}
+
+	return filepath.Join(workingDir, frame.Output)
+}
+
+// missingFrames returns the frames of frameSpec that progress has not saved.
+func missingFrames(frameSpec frames.Spec, progress renderlog.Progress) frames.Spec {
+	saved := map[int]bool{}
+	for _, frame := range progress.Saved {
+		saved[frame.Frame] = true
+	}
+
+	var missing []int
+	for _, frame := range frameSpec.Frames() {
+		if !saved[frame] {
+			missing = append(missing, frame)
+		}
+	}
+
+	return frames.FromFrames(missing)
+}
+
+// renderProgress reports the progress parsed from the render log as heartbeat
+// details and to the workflow running the render.
+type renderProgress struct {
+	temporalClient client.Client
+	parser         *renderlog.Parser
+	// changed is set when the progress changed since it was last signalled.
+	changed bool
+	// signalled is when the progress was last signalled.
+	signalled time.Time
+}
+
+// parse reads a line of the render log, heartbeating straight away when a frame is saved.
+func (p *renderProgress) parse(ctx context.Context, line string) {
+	completed := p.parser.Progress().FramesCompleted
+	if !p.parser.Parse(line) {
+		return
+	}
+
+	p.changed = true
+	if p.parser.Progress().FramesCompleted > completed {
+		p.heartbeat(ctx)
+	}
+}
+
+func (p *renderProgress) heartbeat(ctx context.Context) {
+	activity.RecordHeartbeat(ctx, p.parser.Progress())
+}
+
+// signal sends the progress to the workflow running the render if it changed and progressSignalInterval
+// has passed since it was last sent.
+func (p *renderProgress) signal(ctx context.Context) {
+	if time.Since(p.signalled) < progressSignalInterval {
+		return
+	}
+
+	p.flush(ctx)
+}
+
+// flush sends the progress to the workflow running the render if it changed. The progress is
+// informational, so failing to deliver it does not fail the render.
+func (p *renderProgress) flush(ctx context.Context) {
+	if p.temporalClient == nil || !p.changed {
+		return
+	}
+
+	info := activity.GetInfo(ctx)
+	err := p.temporalClient.SignalWorkflow(ctx, info.WorkflowExecution.ID, info.WorkflowExecution.RunID, RenderProgressSignal, p.parser.Progress())
+	if err != nil {
+		activity.GetLogger(ctx).Warn("Unable to signal render progress.", "Error", err)
+		return
+	}
+
+	p.changed = false
+	p.signalled = time.Now()
 }
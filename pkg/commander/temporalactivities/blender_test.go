@@ -0,0 +1,83 @@
+package temporalactivities
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flowshot-io/commander/pkg/commander/frames"
+	"github.com/flowshot-io/commander/pkg/commander/renderer"
+	"github.com/flowshot-io/commander/pkg/commander/renderlog"
+	"github.com/flowshot-io/commander/pkg/commander/renderoutput"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/mocks"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestRenderFramesActivitySignalsOncePerRun(t *testing.T) {
+	fake, err := renderer.New(renderer.Fake, renderer.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Saved frames only heartbeat, so a render shorter than the signal interval signals its
+	// progress once, when the renderer exits.
+	temporalClient := &mocks.Client{}
+	temporalClient.On("SignalWorkflow", mock.Anything, mock.Anything, mock.Anything, RenderProgressSignal, mock.Anything).Return(nil).Once()
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	blenderAct := NewBlenderActivities(temporalClient, map[string]renderer.Renderer{renderer.Fake: fake}, renderer.Fake, renderoutput.Options{})
+	env.RegisterActivity(blenderAct)
+
+	if _, err := env.ExecuteActivity(blenderAct.RenderFramesActivity, t.TempDir(), frames.Contiguous(1, 5), "", time.Duration(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	temporalClient.AssertExpectations(t)
+
+	progress := temporalClient.Calls[0].Arguments.Get(4).(renderlog.Progress)
+	if progress.FramesCompleted != 5 {
+		t.Fatalf("signalled %d frames completed, want 5", progress.FramesCompleted)
+	}
+}
+
+func TestRenderProjectActivityRendersStartToEndFrame(t *testing.T) {
+	fake, err := renderer.New(renderer.Fake, renderer.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	temporalClient := &mocks.Client{}
+	temporalClient.On("SignalWorkflow", mock.Anything, mock.Anything, mock.Anything, RenderProgressSignal, mock.Anything).Return(nil)
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	blenderAct := NewBlenderActivities(temporalClient, map[string]renderer.Renderer{renderer.Fake: fake}, renderer.Fake, renderoutput.Options{})
+	env.RegisterActivity(blenderAct)
+
+	// Tasks scheduled before RenderFramesActivity carry the start and end frame.
+	if _, err := env.ExecuteActivity(blenderAct.RenderProjectActivity, t.TempDir(), 1, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	progress := temporalClient.Calls[len(temporalClient.Calls)-1].Arguments.Get(4).(renderlog.Progress)
+	if progress.FramesTotal != 3 || progress.FramesCompleted != 3 {
+		t.Fatalf("signalled %d of %d frames completed, want 3 of 3", progress.FramesCompleted, progress.FramesTotal)
+	}
+}
+
+func TestRenderFramesActivityUnknownRenderer(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	blenderAct := NewBlenderActivities(&mocks.Client{}, map[string]renderer.Renderer{}, renderer.Fake, renderoutput.Options{})
+	env.RegisterActivity(blenderAct)
+
+	_, err := env.ExecuteActivity(blenderAct.RenderFramesActivity, t.TempDir(), frames.Contiguous(1, 5), "cycles-x", time.Duration(0))
+
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || appErr.Type() != ErrUnknownRenderer || !appErr.NonRetryable() {
+		t.Fatalf("err = %v, want a non-retryable %s error", err, ErrUnknownRenderer)
+	}
+}
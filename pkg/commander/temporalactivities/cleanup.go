@@ -0,0 +1,42 @@
+package temporalactivities
+
+import (
+	"context"
+
+	"github.com/flowshot-io/x/pkg/artifact"
+	"github.com/flowshot-io/x/pkg/artifactservice"
+	"go.temporal.io/sdk/activity"
+)
+
+type CleanupActivities struct {
+	artifactClient artifactservice.ArtifactServiceClient
+}
+
+func NewCleanupActivities(artifactClient artifactservice.ArtifactServiceClient) *CleanupActivities {
+	return &CleanupActivities{
+		artifactClient: artifactClient,
+	}
+}
+
+// DeleteArtifacts removes the given artifacts from the artifact service. Artifacts that do not exist are skipped.
+func (a *CleanupActivities) DeleteArtifacts(ctx context.Context, artifactNames []string) error {
+	logger := activity.GetLogger(ctx)
+
+	for _, artifactName := range artifactNames {
+		// The artifact service uploads and downloads an artifact under the name of its archive, but
+		// deletes the name it is given as is.
+		err := a.artifactClient.DeleteArtifact(ctx, artifact.New(artifactName).GetName())
+		if isNotFound(err) {
+			logger.Info("DeleteArtifacts skipped missing artifact.", "Artifact", artifactName)
+			continue
+		}
+
+		if err != nil {
+			logger.Error("DeleteArtifacts failed to delete artifact.", "Artifact", artifactName, "Error", err)
+			return err
+		}
+	}
+
+	logger.Info("DeleteArtifacts succeed.", "Artifacts", artifactNames)
+	return nil
+}
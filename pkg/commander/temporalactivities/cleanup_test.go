@@ -0,0 +1,46 @@
+package temporalactivities
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flowshot-io/polystore/pkg/services/fs"
+	"github.com/flowshot-io/x/pkg/artifact"
+	"github.com/flowshot-io/x/pkg/artifactservice"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestDeleteArtifacts(t *testing.T) {
+	store, err := fs.New(fs.Options{WorkingDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := artifactservice.New(artifactservice.Options{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := artifact.New("project-1-10")
+	if err := a.AddFile("", "0001.png", []byte("frame")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.UploadArtifact(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	cleanupAct := NewCleanupActivities(client)
+	env.RegisterActivity(cleanupAct)
+
+	// The render log of the batch was never pushed, which does not fail the cleanup.
+	if _, err := env.ExecuteActivity(cleanupAct.DeleteArtifacts, []string{"project-1-10", "project-1-10-log"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.DownloadArtifact(context.Background(), "project-1-10"); err == nil {
+		t.Fatal("artifact still exists after DeleteArtifacts")
+	}
+}
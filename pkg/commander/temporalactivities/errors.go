@@ -0,0 +1,113 @@
+package temporalactivities
+
+import (
+	"archive/tar"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+
+	"github.com/flowshot-io/commander/pkg/commander/renderer"
+	"github.com/flowshot-io/commander/pkg/commander/supervisor"
+	"go.temporal.io/sdk/temporal"
+)
+
+// Application error types the render activities classify their failures into.
+const (
+	// ErrArtifactNotFound is returned when the project artifact does not exist in the artifact store.
+	ErrArtifactNotFound = "ArtifactNotFound"
+	// ErrCorruptProject is returned when the project cannot be extracted or opened by the renderer.
+	ErrCorruptProject = "CorruptProject"
+	// ErrRendererCrash is returned when the renderer exits with an error of its own.
+	ErrRendererCrash = "RendererCrash"
+	// ErrOutOfMemory is returned when the renderer runs out of memory on the node.
+	ErrOutOfMemory = "OutOfMemory"
+	// ErrRenderTimeout is returned when the render runs past the deadline of the activity.
+	ErrRenderTimeout = "RenderTimeout"
+	// ErrUnknownRenderer is returned when the renderer of a job is not configured on the node.
+	ErrUnknownRenderer = "UnknownRenderer"
+)
+
+// PermanentErrors are the error types of failures that happen again however often, and on
+// whichever node, a batch is retried.
+var PermanentErrors = []string{ErrArtifactNotFound, ErrCorruptProject}
+
+var (
+	// corruptProjectRe matches the lines Blender logs when it cannot read a .blend file.
+	corruptProjectRe = regexp.MustCompile(`(?i)(file format is not supported|not a blend file|failed to read blend file|cannot read file|unable to open)`)
+	// outOfMemoryRe matches the lines Blender and its GPU backends log when memory runs out.
+	outOfMemoryRe = regexp.MustCompile(`(?i)(out of memory|out_of_memory|std::bad_alloc|malloc returns null)`)
+)
+
+// IsPermanent reports whether errType is one of PermanentErrors.
+func IsPermanent(errType string) bool {
+	for _, t := range PermanentErrors {
+		if t == errType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// classifyPullError returns the failure to pull a project artifact as a typed application error.
+func classifyPullError(err error) error {
+	var corrupt *flate.CorruptInputError
+	switch {
+	case isNotFound(err):
+		return temporal.NewNonRetryableApplicationError(err.Error(), ErrArtifactNotFound, err)
+	case errors.Is(err, gzip.ErrHeader), errors.Is(err, gzip.ErrChecksum), errors.Is(err, tar.ErrHeader), errors.As(err, &corrupt):
+		return temporal.NewNonRetryableApplicationError(err.Error(), ErrCorruptProject, err)
+	default:
+		return err
+	}
+}
+
+// classifyRenderError returns the failure of a render as a typed application error, reading the
+// cause from the last lines of the render log where the exit of the renderer does not tell it.
+// Running out of memory is not retried on the node, but another node may have more.
+func classifyRenderError(err error, tail []string) error {
+	msg := err.Error()
+	if len(tail) > 0 {
+		msg = fmt.Sprintf("%s\nLast lines of the render log:\n%s", msg, strings.Join(tail, "\n"))
+	}
+
+	log := strings.Join(tail, "\n")
+	var exitErr *supervisor.ExitError
+	isExit := errors.As(err, &exitErr)
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return temporal.NewApplicationErrorWithCause(msg, ErrRenderTimeout, err)
+	case errors.Is(err, renderer.ErrInvalidProject), corruptProjectRe.MatchString(log):
+		return temporal.NewNonRetryableApplicationError(msg, ErrCorruptProject, err)
+	case outOfMemoryRe.MatchString(log), isExit && exitErr.Signal == "killed" && !exitErr.Stopped:
+		// A SIGKILL the supervisor did not send is most likely the kernel's OOM killer.
+		return temporal.NewNonRetryableApplicationError(msg, ErrOutOfMemory, err)
+	case isExit:
+		return temporal.NewApplicationErrorWithCause(msg, ErrRendererCrash, err)
+	case len(tail) == 0:
+		return err
+	default:
+		return fmt.Errorf("%w\nLast lines of the render log:\n%s", err, log)
+	}
+}
+
+// isNotFound reports whether err is the artifact store reporting a missing artifact.
+func isNotFound(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || awsErrorCode(err) == "NoSuchKey" || awsErrorCode(err) == "NotFound"
+}
+
+// awsErrorCode returns the code of an error from the S3 artifact store, without depending on the AWS SDK.
+func awsErrorCode(err error) string {
+	var coded interface{ Code() string }
+	if errors.As(err, &coded) {
+		return coded.Code()
+	}
+
+	return ""
+}
@@ -0,0 +1,72 @@
+package temporalactivities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/flowshot-io/commander/pkg/commander/renderer"
+	"github.com/flowshot-io/commander/pkg/commander/supervisor"
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestClassifyRenderError(t *testing.T) {
+	crashed := &supervisor.ExitError{Name: "rocketblend", Code: 1}
+	killed := &supervisor.ExitError{Name: "rocketblend", Code: -1, Signal: "killed"}
+	stopped := &supervisor.ExitError{Name: "rocketblend", Code: -1, Signal: "killed", Stopped: true}
+
+	tests := []struct {
+		name         string
+		err          error
+		tail         []string
+		wantType     string
+		nonRetryable bool
+	}{
+		{"deadline", context.DeadlineExceeded, nil, ErrRenderTimeout, false},
+		{"deadline wrapped", fmt.Errorf("render: %w", context.DeadlineExceeded), []string{"Fra:1 Mem:12.00M"}, ErrRenderTimeout, false},
+		{"invalid project", renderer.ErrInvalidProject, nil, ErrCorruptProject, true},
+		{"corrupt blend file", crashed, []string{"Error: File format is not supported in file '/work/project.blend'"}, ErrCorruptProject, true},
+		{"out of memory", crashed, []string{"Error: Out of memory in cuMemAlloc(&device_pointer, size) (device_cuda.cpp:1011)"}, ErrOutOfMemory, true},
+		{"killed", killed, nil, ErrOutOfMemory, true},
+		{"stopped", stopped, nil, ErrRendererCrash, false},
+		{"crash", crashed, []string{"Segmentation fault"}, ErrRendererCrash, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyRenderError(tt.err, tt.tail)
+
+			var appErr *temporal.ApplicationError
+			if !errors.As(err, &appErr) {
+				t.Fatalf("classifyRenderError() = %v, want an application error", err)
+			}
+
+			if appErr.Type() != tt.wantType {
+				t.Errorf("type = %q, want %q", appErr.Type(), tt.wantType)
+			}
+
+			if appErr.NonRetryable() != tt.nonRetryable {
+				t.Errorf("non-retryable = %t, want %t", appErr.NonRetryable(), tt.nonRetryable)
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Errorf("classifyRenderError() = %v, want it to wrap %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestClassifyRenderErrorUnknown(t *testing.T) {
+	cause := errors.New("pipe closed")
+
+	if err := classifyRenderError(cause, nil); err != cause {
+		t.Errorf("classifyRenderError() without a log = %v, want %v", err, cause)
+	}
+
+	err := classifyRenderError(cause, []string{"Fra:1 Mem:12.00M"})
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) || !errors.Is(err, cause) {
+		t.Errorf("classifyRenderError() with a log = %v, want %v with the log appended", err, cause)
+	}
+}
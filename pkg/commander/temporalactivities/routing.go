@@ -0,0 +1,44 @@
+package temporalactivities
+
+import (
+	"context"
+
+	"github.com/flowshot-io/commander/pkg/commander/noderegistry"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+)
+
+type RoutingActivities struct {
+	temporalClient client.Client
+}
+
+func NewRoutingActivities(temporalClient client.Client) *RoutingActivities {
+	return &RoutingActivities{
+		temporalClient: temporalClient,
+	}
+}
+
+// LocateArtifact returns the task queues of the online nodes that have the artifact cached.
+// Routing is best effort, so an unavailable registry returns no queues rather than an error.
+func (a *RoutingActivities) LocateArtifact(ctx context.Context, artifactName string) ([]string, error) {
+	logger := activity.GetLogger(ctx)
+
+	nodes, err := noderegistry.Nodes(ctx, a.temporalClient)
+	if err != nil {
+		logger.Warn("LocateArtifact unable to query node registry.", "Error", err)
+		return nil, nil
+	}
+
+	var queues []string
+	for _, node := range nodes {
+		for _, artifact := range node.Artifacts {
+			if artifact == artifactName {
+				queues = append(queues, node.Queue)
+				break
+			}
+		}
+	}
+
+	logger.Info("LocateArtifact succeed.", "Artifact", artifactName, "Queues", queues)
+	return queues, nil
+}
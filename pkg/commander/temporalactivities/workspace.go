@@ -0,0 +1,66 @@
+package temporalactivities
+
+import (
+	"context"
+	"errors"
+
+	"github.com/flowshot-io/commander/pkg/commander/artifactcache"
+	"github.com/flowshot-io/commander/pkg/commander/workspace"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	// ErrInsufficientSpace is the application error type returned when a node has no room for a workspace.
+	ErrInsufficientSpace = "InsufficientSpace"
+
+	// extractRatio estimates the extracted size of a project from its compressed archive.
+	extractRatio = 2
+)
+
+type WorkspaceActivities struct {
+	workspaces *workspace.Manager
+	cache      *artifactcache.Cache
+	// queue is the task queue only this node polls, so a workspace can be removed after its session completes.
+	queue string
+	// defaultSize is reserved for projects that are not cached, as their size is not known before the download.
+	defaultSize int64
+}
+
+func NewWorkspaceActivities(workspaces *workspace.Manager, cache *artifactcache.Cache, queue string, defaultSize int64) *WorkspaceActivities {
+	return &WorkspaceActivities{
+		workspaces:  workspaces,
+		cache:       cache,
+		queue:       queue,
+		defaultSize: defaultSize,
+	}
+}
+
+// CreateWorkspace reserves disk space for the project artifact and creates the workspace the render runs in.
+func (a *WorkspaceActivities) CreateWorkspace(ctx context.Context, id string, artifactName string) (workspace.Workspace, error) {
+	logger := activity.GetLogger(ctx)
+
+	size := a.defaultSize
+	if archiveSize, ok := a.cache.Size(artifactName); ok {
+		size = archiveSize * extractRatio
+	}
+
+	dir, err := a.workspaces.Create(id, size)
+	if errors.Is(err, workspace.ErrInsufficientSpace) {
+		// Another node may have room, so fail the session instead of retrying on this one.
+		logger.Warn("CreateWorkspace has no room for workspace.", "Error", err)
+		return workspace.Workspace{}, temporal.NewNonRetryableApplicationError(err.Error(), ErrInsufficientSpace, err)
+	}
+	if err != nil {
+		logger.Error("CreateWorkspace failed to create workspace.", "Error", err)
+		return workspace.Workspace{}, err
+	}
+
+	logger.Info("CreateWorkspace succeed.", "Dir", dir, "Reserved", size)
+	return workspace.Workspace{ID: id, Dir: dir, Queue: a.queue}, nil
+}
+
+// RemoveWorkspace deletes the workspace, or keeps it for debugging when the render failed.
+func (a *WorkspaceActivities) RemoveWorkspace(ctx context.Context, id string, failed bool) error {
+	return a.workspaces.Remove(id, failed)
+}
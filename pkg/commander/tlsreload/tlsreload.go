@@ -0,0 +1,192 @@
+// Package tlsreload serves TLS with certificates that are reloaded from disk
+// when they change, so rotated certificates are picked up without a restart.
+//
+// The files are checked for changes on new connections, at most once every
+// CheckInterval. A certificate that fails to load is logged and the previous
+// one is kept until the files are fixed.
+package tlsreload
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/flowshot-io/x/pkg/logger"
+)
+
+// DefaultCheckInterval is used when Options does not set CheckInterval.
+const DefaultCheckInterval = 30 * time.Second
+
+type (
+	Options struct {
+		Logger   logger.Logger
+		CertFile string
+		KeyFile  string
+		// ClientCAFile turns on mutual TLS: clients must present a certificate signed by one of its CAs.
+		ClientCAFile string
+		// CheckInterval is how often the files are checked for changes.
+		CheckInterval time.Duration
+	}
+
+	// Reloader holds the current certificates. It is safe for concurrent use.
+	Reloader struct {
+		logger        logger.Logger
+		certFile      string
+		keyFile       string
+		clientCAFile  string
+		checkInterval time.Duration
+
+		mu        sync.Mutex
+		checkedAt time.Time
+		modTimes  map[string]time.Time
+		config    *tls.Config
+	}
+)
+
+// New loads the certificates, failing if they cannot be loaded.
+func New(opts Options) (*Reloader, error) {
+	if opts.Logger == nil {
+		opts.Logger = logger.NoOp()
+	}
+
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, fmt.Errorf("cert file and key file are required")
+	}
+
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = DefaultCheckInterval
+	}
+
+	r := &Reloader{
+		logger:        opts.Logger,
+		certFile:      opts.CertFile,
+		keyFile:       opts.KeyFile,
+		clientCAFile:  opts.ClientCAFile,
+		checkInterval: opts.CheckInterval,
+	}
+
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	r.modTimes = modTimes
+	r.config = config
+	r.checkedAt = time.Now()
+
+	return r, nil
+}
+
+// Config returns the server TLS config, which serves the current certificates to every new connection.
+func (r *Reloader) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current(), nil
+		},
+	}
+}
+
+// current returns the config for a new connection, reloading the files first if they changed.
+func (r *Reloader) current() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) < r.checkInterval {
+		return r.config
+	}
+	r.checkedAt = time.Now()
+
+	modTimes, err := r.statFiles()
+	if err != nil {
+		r.logger.Warn("Unable to check TLS certificates", map[string]interface{}{"Error": err.Error()})
+		return r.config
+	}
+
+	if !r.changed(modTimes) {
+		return r.config
+	}
+
+	config, err := r.load()
+	if err != nil {
+		r.logger.Warn("Unable to reload TLS certificates, keeping the previous ones", map[string]interface{}{"Error": err.Error()})
+		return r.config
+	}
+
+	r.logger.Info("Reloaded TLS certificates", map[string]interface{}{"CertFile": r.certFile})
+	r.modTimes = modTimes
+	r.config = config
+	return r.config
+}
+
+func (r *Reloader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		// The config replaces the one gRPC set up, so it has to offer HTTP/2 itself.
+		NextProtos: []string{"h2"},
+	}
+
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client CA: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", r.clientCAFile)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+
+	return files
+}
+
+func (r *Reloader) statFiles() (map[string]time.Time, error) {
+	modTimes := map[string]time.Time{}
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+
+		modTimes[file] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+func (r *Reloader) changed(modTimes map[string]time.Time) bool {
+	for file, modTime := range modTimes {
+		if !modTime.Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,302 @@
+package tlsreload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// authority is a CA issuing the certificates of a test.
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func newAuthority(t *testing.T) *authority {
+	t.Helper()
+
+	key := newKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate with serial for localhost and its key, both PEM encoded.
+func (a *authority) issue(t *testing.T, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+
+	key := newKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (a *authority) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(a.cert)
+	return pool
+}
+
+// writeFile writes data to path with a modification time offset from now, so rewrites within
+// the resolution of the file system clock are still seen as changes.
+func writeFile(t *testing.T, path string, data []byte, offset time.Duration) {
+	t.Helper()
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Now().Add(offset)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serve accepts TLS connections with config and sends the result of each server handshake.
+func serve(t *testing.T, config *tls.Config) (string, <-chan error) {
+	t.Helper()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	handshakes := make(chan error, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			handshakes <- conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	return listener.Addr().String(), handshakes
+}
+
+// dial returns the serial of the certificate the server at addr presented.
+func dial(t *testing.T, addr string, config *tls.Config) (int64, error) {
+	t.Helper()
+
+	conn, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+type files struct {
+	cert, key, clientCA string
+}
+
+func newFiles(t *testing.T) files {
+	dir := t.TempDir()
+	return files{
+		cert:     filepath.Join(dir, "server.crt"),
+		key:      filepath.Join(dir, "server.key"),
+		clientCA: filepath.Join(dir, "client-ca.crt"),
+	}
+}
+
+func TestReload(t *testing.T) {
+	ca := newAuthority(t)
+	f := newFiles(t)
+
+	cert, key := ca.issue(t, 1, x509.ExtKeyUsageServerAuth)
+	writeFile(t, f.cert, cert, -time.Minute)
+	writeFile(t, f.key, key, -time.Minute)
+
+	r, err := New(Options{CertFile: f.cert, KeyFile: f.key, CheckInterval: time.Nanosecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, _ := serve(t, r.Config())
+	clientConfig := &tls.Config{RootCAs: ca.pool(), ServerName: "localhost"}
+
+	if serial, err := dial(t, addr, clientConfig); err != nil || serial != 1 {
+		t.Fatalf("dial() = %d, %v, want certificate 1", serial, err)
+	}
+
+	// Rotate the certificate and key on disk.
+	cert, key = ca.issue(t, 2, x509.ExtKeyUsageServerAuth)
+	writeFile(t, f.cert, cert, 0)
+	writeFile(t, f.key, key, 0)
+
+	if serial, err := dial(t, addr, clientConfig); err != nil || serial != 2 {
+		t.Fatalf("dial() after rotating = %d, %v, want certificate 2", serial, err)
+	}
+
+	// A broken certificate is not loaded, the previous one is kept.
+	writeFile(t, f.cert, []byte("not a certificate"), time.Minute)
+
+	if serial, err := dial(t, addr, clientConfig); err != nil || serial != 2 {
+		t.Fatalf("dial() with a broken certificate = %d, %v, want certificate 2", serial, err)
+	}
+}
+
+func TestReloadWaitsForCheckInterval(t *testing.T) {
+	ca := newAuthority(t)
+	f := newFiles(t)
+
+	cert, key := ca.issue(t, 1, x509.ExtKeyUsageServerAuth)
+	writeFile(t, f.cert, cert, -time.Minute)
+	writeFile(t, f.key, key, -time.Minute)
+
+	r, err := New(Options{CertFile: f.cert, KeyFile: f.key, CheckInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, _ := serve(t, r.Config())
+
+	cert, key = ca.issue(t, 2, x509.ExtKeyUsageServerAuth)
+	writeFile(t, f.cert, cert, 0)
+	writeFile(t, f.key, key, 0)
+
+	if serial, err := dial(t, addr, &tls.Config{RootCAs: ca.pool(), ServerName: "localhost"}); err != nil || serial != 1 {
+		t.Fatalf("dial() = %d, %v, want certificate 1 until the files are checked again", serial, err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	serverCA := newAuthority(t)
+	clientCA := newAuthority(t)
+	otherCA := newAuthority(t)
+	f := newFiles(t)
+
+	cert, key := serverCA.issue(t, 1, x509.ExtKeyUsageServerAuth)
+	writeFile(t, f.cert, cert, 0)
+	writeFile(t, f.key, key, 0)
+	writeFile(t, f.clientCA, clientCA.pem, 0)
+
+	r, err := New(Options{CertFile: f.cert, KeyFile: f.key, ClientCAFile: f.clientCA})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, handshakes := serve(t, r.Config())
+
+	clientCert := func(ca *authority) []tls.Certificate {
+		cert, key := ca.issue(t, 10, x509.ExtKeyUsageClientAuth)
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return []tls.Certificate{pair}
+	}
+
+	tests := []struct {
+		name    string
+		certs   []tls.Certificate
+		wantErr bool
+	}{
+		{"client certificate", clientCert(clientCA), false},
+		{"no client certificate", nil, true},
+		{"client certificate of another CA", clientCert(otherCA), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// With TLS 1.3 the client only learns of the rejection after its handshake, so check the server's.
+			dial(t, addr, &tls.Config{RootCAs: serverCA.pool(), ServerName: "localhost", Certificates: tt.certs})
+
+			select {
+			case err := <-handshakes:
+				if (err != nil) != tt.wantErr {
+					t.Errorf("server handshake error = %v, want error %t", err, tt.wantErr)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("server did not finish the handshake")
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	ca := newAuthority(t)
+	f := newFiles(t)
+
+	cert, key := ca.issue(t, 1, x509.ExtKeyUsageServerAuth)
+	writeFile(t, f.cert, cert, 0)
+	writeFile(t, f.key, key, 0)
+	writeFile(t, f.clientCA, []byte("not a certificate"), 0)
+
+	for _, opts := range []Options{
+		{},
+		{CertFile: f.cert},
+		{CertFile: f.cert, KeyFile: f.cert},
+		{CertFile: f.cert, KeyFile: filepath.Join(filepath.Dir(f.key), "missing.key")},
+		{CertFile: f.cert, KeyFile: f.key, ClientCAFile: f.clientCA},
+	} {
+		if _, err := New(opts); err == nil {
+			t.Errorf("New(%+v) = nil error, want an error", opts)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Package workspace manages the directories renders run in on a blendernode.
+//
+// Every workspace reserves the disk space its project is expected to need, and
+// a workspace is only created when the free space on the root directory covers
+// the reservations of all open workspaces plus the configured headroom.
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flowshot-io/x/pkg/logger"
+)
+
+const (
+	// DefaultRoot is used when Options does not set Root.
+	DefaultRoot = "temp"
+
+	// failedDir holds the failed workspaces kept for debugging.
+	failedDir = ".failed"
+)
+
+// ErrInsufficientSpace is returned when the root directory does not have room for a workspace.
+var ErrInsufficientSpace = errors.New("insufficient disk space for workspace")
+
+type (
+	Options struct {
+		Logger logger.Logger
+		// Root is the directory workspaces are created in.
+		Root string
+		// MinFree is the free space in bytes left untouched by reservations.
+		MinFree int64
+		// KeepFailedFor keeps the workspaces of failed renders for debugging. Zero removes them straight away.
+		KeepFailedFor time.Duration
+	}
+
+	// Workspace is a directory a single render runs in.
+	Workspace struct {
+		ID  string
+		Dir string
+		// Queue is the task queue of the node the workspace is on.
+		Queue string
+	}
+
+	// Manager creates and removes workspaces. It is safe for concurrent use.
+	Manager struct {
+		logger        logger.Logger
+		root          string
+		minFree       int64
+		keepFailedFor time.Duration
+
+		mu           sync.Mutex
+		reservations map[string]int64
+	}
+)
+
+// New creates the root directory and removes failed workspaces kept past their retention.
+func New(opts Options) (*Manager, error) {
+	if opts.Logger == nil {
+		opts.Logger = logger.NoOp()
+	}
+
+	if opts.Root == "" {
+		opts.Root = DefaultRoot
+	}
+
+	if opts.MinFree < 0 {
+		return nil, fmt.Errorf("min free space must not be negative")
+	}
+
+	root, err := filepath.Abs(opts.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, failedDir), 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create workspace root: %w", err)
+	}
+
+	m := &Manager{
+		logger:        opts.Logger,
+		root:          root,
+		minFree:       opts.MinFree,
+		keepFailedFor: opts.KeepFailedFor,
+		reservations:  map[string]int64{},
+	}
+
+	m.sweep()
+
+	return m, nil
+}
+
+// Create reserves size bytes and creates the workspace for id. It returns
+// ErrInsufficientSpace when the reservation does not fit on disk.
+func (m *Manager) Create(id string, size int64) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	free, err := freeSpace(m.root)
+	if err != nil {
+		return "", fmt.Errorf("unable to get free disk space: %w", err)
+	}
+
+	reserved := int64(0)
+	for _, r := range m.reservations {
+		reserved += r
+	}
+
+	if free-reserved-size < m.minFree {
+		return "", fmt.Errorf("%w: %d bytes needed, %d free, %d reserved, %d kept free", ErrInsufficientSpace, size, free, reserved, m.minFree)
+	}
+
+	dir := m.path(id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	m.reservations[id] = size
+	return dir, nil
+}
+
+// Grow raises the reservation of the workspace in dir to size bytes, once the
+// project is known to need more than was reserved for it. It returns
+// ErrInsufficientSpace when the extra space does not fit on disk, and leaves
+// directories that are not open workspaces alone.
+func (m *Manager) Grow(dir string, size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	id, ok := "", false
+	for reservedID := range m.reservations {
+		if m.path(reservedID) == dir {
+			id, ok = reservedID, true
+			break
+		}
+	}
+
+	if !ok || size <= m.reservations[id] {
+		return nil
+	}
+
+	free, err := freeSpace(m.root)
+	if err != nil {
+		return fmt.Errorf("unable to get free disk space: %w", err)
+	}
+
+	reserved := int64(0)
+	for _, r := range m.reservations {
+		reserved += r
+	}
+
+	extra := size - m.reservations[id]
+	if free-reserved-extra < m.minFree {
+		return fmt.Errorf("%w: %d more bytes needed, %d free, %d reserved, %d kept free", ErrInsufficientSpace, extra, free, reserved, m.minFree)
+	}
+
+	m.reservations[id] = size
+	return nil
+}
+
+// Remove deletes the workspace for id and releases its reservation. The
+// workspace of a failed render is kept for KeepFailedFor when it is set.
+func (m *Manager) Remove(id string, failed bool) error {
+	m.mu.Lock()
+	delete(m.reservations, id)
+	m.mu.Unlock()
+
+	defer m.sweep()
+
+	dir := m.path(id)
+	if failed && m.keepFailedFor > 0 {
+		kept := filepath.Join(m.root, failedDir, filepath.Base(dir))
+		if err := os.RemoveAll(kept); err != nil {
+			return err
+		}
+
+		if err := os.Rename(dir, kept); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		// The modification time marks when the retention window started.
+		now := time.Now()
+		_ = os.Chtimes(kept, now, now)
+
+		m.logger.Info("Keeping failed workspace", map[string]interface{}{"Dir": kept, "Until": now.Add(m.keepFailedFor)})
+		return nil
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// sweep removes the failed workspaces kept past KeepFailedFor.
+func (m *Manager) sweep() {
+	entries, err := os.ReadDir(filepath.Join(m.root, failedDir))
+	if err != nil {
+		m.logger.Warn("Unable to read failed workspaces", map[string]interface{}{"Error": err.Error()})
+		return
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < m.keepFailedFor {
+			continue
+		}
+
+		dir := filepath.Join(m.root, failedDir, entry.Name())
+		if err := os.RemoveAll(dir); err != nil {
+			m.logger.Warn("Unable to remove failed workspace", map[string]interface{}{"Dir": dir, "Error": err.Error()})
+		}
+	}
+}
+
+// path returns the directory of the workspace for id. Workflow IDs contain
+// slashes, which are flattened so every workspace is a direct child of root.
+func (m *Manager) path(id string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(id)
+	return filepath.Join(m.root, name)
+}
@@ -0,0 +1,49 @@
+package workspace
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGrow(t *testing.T) {
+	m, err := New(Options{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := m.Create("job/batch", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Grow(dir, 1<<20); err != nil {
+		t.Fatalf("grow by 1MB: %v", err)
+	}
+
+	if got := m.reservations["job/batch"]; got != 1<<20 {
+		t.Fatalf("reservation = %d, want %d", got, 1<<20)
+	}
+
+	// A smaller size never shrinks the reservation.
+	if err := m.Grow(dir, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.reservations["job/batch"]; got != 1<<20 {
+		t.Fatalf("reservation after smaller grow = %d, want %d", got, 1<<20)
+	}
+
+	free, err := freeSpace(m.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Grow(dir, free+(2<<20)); !errors.Is(err, ErrInsufficientSpace) {
+		t.Fatalf("grow past free space = %v, want ErrInsufficientSpace", err)
+	}
+
+	// Directories that are not workspaces are left alone.
+	if err := m.Grow(t.TempDir(), free+(2<<20)); err != nil {
+		t.Fatalf("grow of a directory that is not a workspace: %v", err)
+	}
+}